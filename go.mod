@@ -8,7 +8,7 @@ require (
 	github.com/Jeffail/gabs v1.1.1
 	github.com/Jeffail/gabs/v2 v2.1.0
 	github.com/SchweizerischeBundesbahnen/gotc v0.0.0-20200107101414-7bbd1a5fe5d2
-	github.com/aws/aws-sdk-go v1.16.30
+	github.com/aws/aws-sdk-go v1.30.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/gin-contrib/cors v0.0.0-20190101123304-5e7acb10687f
 	github.com/gin-gonic/gin v1.3.0
@@ -25,7 +25,8 @@ require (
 	github.com/mpeter/go-towerapi v0.0.0-20160920185410-301c48b65cf7
 	github.com/mpeter/sling v0.0.0-20160821062127-52e88a7b75a5
 	github.com/patrickmn/go-cache v2.1.0+incompatible
-	github.com/pkg/errors v0.8.1
+	github.com/pkg/errors v0.9.1
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sirupsen/logrus v1.3.0
 	github.com/spf13/viper v1.3.1
 	github.com/tidwall/gjson v1.3.2 // indirect
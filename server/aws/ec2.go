@@ -1,21 +1,34 @@
 package aws
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	ec2ListError  = "Instances can't be listed. Please open a ticket"
-	ec2StartError = "Instances can't be started. Please open a ticket"
-	ec2StopError  = "Instances can't be stopped. Please open a ticket"
+	ec2ListError    = "Instances can't be listed. Please open a ticket"
+	ec2StartError   = "Instances can't be started. Please open a ticket"
+	ec2StopError    = "Instances can't be stopped. Please open a ticket"
+	ec2RebootError  = "Instances can't be rebooted. Please open a ticket"
+	ec2ResizeError  = "The instance type can't be changed. Please open a ticket"
+	ec2ConsoleError = "The console output can't be read. Please open a ticket"
+)
+
+const (
+	ownerTag      = "Owner"
+	ownerGroupTag = "OwnerGroup"
 )
 
 func listEC2InstancesHandler(c *gin.Context) {
@@ -23,7 +36,10 @@ func listEC2InstancesHandler(c *gin.Context) {
 
 	log.Println(username + " lists EC2 Instances")
 
-	instances, err := listEC2InstancesByUsername(username)
+	// Only has an effect for members of the admin group
+	showall, _ := strconv.ParseBool(c.Query("showall"))
+
+	instances, err := listEC2InstancesByUsername(username, showall)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 	} else {
@@ -34,8 +50,12 @@ func listEC2InstancesHandler(c *gin.Context) {
 func deleteEC2InstanceSnapshotHandler(c *gin.Context) {
 	username := common.GetUserName(c)
 	snapshotid := c.Param("snapshotid")
-	account := c.Param("account")
-	err := deleteSnapshot(snapshotid, account)
+	instance, _, err := getSnapshot(snapshotid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	err = deleteSnapshot(snapshotid, instance.Account, username)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAwsAPIError})
 		return
@@ -48,7 +68,16 @@ func createEC2InstanceSnapshotHandler(c *gin.Context) {
 	username := common.GetUserName(c)
 	var data common.CreateSnapshotCommand
 	if c.BindJSON(&data) == nil {
-		snapshot, err := createSnapshot(data.VolumeId, data.InstanceId, data.Description, data.Account)
+		instance, err := getInstance(data.InstanceId, username)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+		if !hasVolume(instance, data.VolumeId) {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Volume " + data.VolumeId + " is not attached to instance " + data.InstanceId})
+			return
+		}
+		snapshot, err := createSnapshot(data.VolumeId, data.InstanceId, data.Description, instance.Account, username)
 		if err != nil {
 			log.Println(err)
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAwsAPIError})
@@ -61,6 +90,16 @@ func createEC2InstanceSnapshotHandler(c *gin.Context) {
 	c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
 }
 
+// postEC2InstanceHandler is needed, because the router doesn't allow
+// static path segments next to the :state wildcard
+func postEC2InstanceHandler(c *gin.Context) {
+	if c.Param("state") == "type" {
+		setEC2InstanceTypeHandler(c)
+		return
+	}
+	setEC2InstanceStateHandler(c)
+}
+
 func setEC2InstanceStateHandler(c *gin.Context) {
 	username := common.GetUserName(c)
 	instanceid := c.Param("instanceid")
@@ -88,13 +127,80 @@ func setEC2InstanceStateHandler(c *gin.Context) {
 			return
 		}
 		c.JSON(http.StatusOK, res)
+	case "reboot":
+		res, err := rebootEC2Instance(instanceid, username, account)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, res)
 	default:
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
 	}
 }
 
-func deleteSnapshot(snapshotid string, account string) error {
-	svc, err := GetEC2ClientForAccount(account)
+func setEC2InstanceTypeHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+
+	var data common.EC2InstanceTypeCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if err := validateInstanceType(data.InstanceType); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	log.Print(username + " requested instance " + instanceid + " to change type to " + data.InstanceType)
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	res, err := changeEC2InstanceType(instance, data.InstanceType, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func getEC2InstanceConsoleHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	res, err := getEC2InstanceConsoleOutput(instanceid, username, instance.Account)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// validateInstanceType checks if the instance type is in the allow-list (see sample config)
+func validateInstanceType(instanceType string) error {
+	allowedTypes := config.Config().GetStringSlice("aws_ec2_instance_types")
+	if len(allowedTypes) == 0 {
+		log.Println("WARNING: Env variable 'AWS_EC2_INSTANCE_TYPES' must be specified")
+		return errors.New(common.ConfigNotSetError)
+	}
+	for _, t := range allowedTypes {
+		if t == instanceType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Instance type %v is not allowed. Allowed types: %v", instanceType, strings.Join(allowedTypes, ", "))
+}
+
+func deleteSnapshot(snapshotid string, account string, username string) error {
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		return err
 	}
@@ -107,13 +213,13 @@ func deleteSnapshot(snapshotid string, account string) error {
 	return nil
 }
 
-func createSnapshot(volumeid string, instanceid string, description string, account string) (*ec2.Snapshot, error) {
-	tags, err := getTags(volumeid, account)
+func createSnapshot(volumeid string, instanceid string, description string, account string, username string) (*ec2.Snapshot, error) {
+	tags, err := getTags(volumeid, account, username)
 	if err != nil {
 		log.Println("Error getting tags: " + err.Error())
 		return nil, err
 	}
-	deviceName, err := getDeviceName(volumeid, account)
+	deviceName, err := getDeviceName(volumeid, account, username)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +236,7 @@ func createSnapshot(volumeid string, instanceid string, description string, acco
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, err
@@ -144,14 +250,40 @@ func createSnapshot(volumeid string, instanceid string, description string, acco
 	return snapshot, nil
 }
 
+// getInstance returns the instance, if the user is an owner of it
 func getInstance(instanceid string, username string) (*common.Instance, error) {
-	instances, err := listEC2InstancesByUsername(username)
-	if err != nil {
-		return nil, err
+	groups := getGroups(username)
+	filters := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name: aws.String("instance-id"),
+				Values: []*string{
+					aws.String(instanceid),
+				},
+			},
+		},
 	}
-	for _, instance := range instances.Instances {
-		if instance.InstanceId == instanceid {
-			return &instance, nil
+	for _, account := range []string{accountNonProd, accountProd} {
+		svc, err := GetEC2ClientForAccount(account, username)
+		if err != nil {
+			return nil, err
+		}
+		result, err := svc.DescribeInstances(filters)
+		if err != nil {
+			log.Print("Unable to get instance (DescribeInstances API call): " + err.Error())
+			return nil, errors.New(ec2ListError)
+		}
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				if !isInstanceOwner(instance.Tags, username, groups) {
+					log.Printf("%v is not an owner of instance %v", username, instanceid)
+					return nil, errors.New(ec2ListError)
+				}
+				snapshots, _ := listSnapshots(instance, account, username)
+				volumes := listVolumes(instance)
+				i := getInstanceStruct(instance, account, username, snapshots, volumes)
+				return &i, nil
+			}
 		}
 	}
 	log.Println("Could not find an instance with id: " + instanceid)
@@ -165,7 +297,7 @@ func startEC2Instance(instanceid string, username string, account string) (*comm
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, errors.New(ec2StartError)
@@ -206,7 +338,7 @@ func stopEC2Instance(instanceid string, username string, account string) (*commo
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, errors.New(ec2StopError)
@@ -241,15 +373,115 @@ func stopEC2Instance(instanceid string, username string, account string) (*commo
 	return result, nil
 }
 
-func listEC2InstancesByUsername(username string) (*common.InstanceListResponse, error) {
+func rebootEC2Instance(instanceid string, username string, account string) (*common.Instance, error) {
+	input := &ec2.RebootInstancesInput{
+		InstanceIds: []*string{
+			aws.String(instanceid),
+		},
+	}
+
+	svc, err := GetEC2ClientForAccount(account, username)
+	if err != nil {
+		log.Println("Error getting EC2 client: " + err.Error())
+		return nil, errors.New(ec2RebootError)
+	}
+
+	_, err = svc.RebootInstances(input)
+	if err != nil {
+		log.Println("Error rebooting EC2 instance (RebootInstances API call): " + err.Error())
+		return nil, errors.New(ec2RebootError)
+	}
+
+	result, err := getInstance(instanceid, username)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// changeEC2InstanceType stops the instance, changes the type and starts
+// the instance again, if it was running before
+func changeEC2InstanceType(instance *common.Instance, instanceType string, username string) (*common.Instance, error) {
+	if instance.InstanceType == instanceType {
+		return instance, nil
+	}
+
+	svc, err := GetEC2ClientForAccount(instance.Account, username)
+	if err != nil {
+		log.Println("Error getting EC2 client: " + err.Error())
+		return nil, errors.New(ec2ResizeError)
+	}
+
+	wasRunning := instance.State == ec2.InstanceStateNameRunning
+	if instance.State != ec2.InstanceStateNameStopped {
+		if _, err := stopEC2Instance(instance.InstanceId, username, instance.Account); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = svc.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(instance.InstanceId),
+		InstanceType: &ec2.AttributeValue{
+			Value: aws.String(instanceType),
+		},
+	})
+	if err != nil {
+		log.Println("Error changing EC2 instance type (ModifyInstanceAttribute API call): " + err.Error())
+		return nil, errors.New(ec2ResizeError)
+	}
+
+	if wasRunning {
+		return startEC2Instance(instance.InstanceId, username, instance.Account)
+	}
+	return getInstance(instance.InstanceId, username)
+}
+
+func getEC2InstanceConsoleOutput(instanceid string, username string, account string) (*common.EC2ConsoleOutputResponse, error) {
+	svc, err := GetEC2ClientForAccount(account, username)
+	if err != nil {
+		log.Println("Error getting EC2 client: " + err.Error())
+		return nil, errors.New(ec2ConsoleError)
+	}
+
+	output, err := svc.GetConsoleOutput(&ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(instanceid),
+		Latest:     aws.Bool(true),
+	})
+	if err != nil {
+		log.Println("Error getting EC2 console output (GetConsoleOutput API call): " + err.Error())
+		return nil, errors.New(ec2ConsoleError)
+	}
+
+	result := common.EC2ConsoleOutputResponse{
+		InstanceId: instanceid,
+		Timestamp:  output.Timestamp,
+	}
+	// The output is empty, if the instance didn't write anything yet
+	if output.Output != nil {
+		decoded, err := base64.StdEncoding.DecodeString(*output.Output)
+		if err != nil {
+			log.Println("Error decoding EC2 console output: " + err.Error())
+			return nil, errors.New(ec2ConsoleError)
+		}
+		result.Output = string(decoded)
+	}
+	return &result, nil
+}
+
+// listEC2InstancesByUsername returns the instances the user is an owner of.
+// Members of the admin group get all instances with showall.
+func listEC2InstancesByUsername(username string, showall bool) (*common.InstanceListResponse, error) {
 	result := common.InstanceListResponse{
 		Instances: []common.Instance{},
 	}
-	nonprodInstances, err := listEC2InstancesByUsernameForAccount(username, accountNonProd)
+	groups := getGroups(username)
+	showall = showall && isEC2Admin(groups)
+
+	nonprodInstances, err := listEC2InstancesByUsernameForAccount(username, groups, showall, accountNonProd)
 	if err != nil {
 		return nil, err
 	}
-	prodInstances, err := listEC2InstancesByUsernameForAccount(username, accountProd)
+	prodInstances, err := listEC2InstancesByUsernameForAccount(username, groups, showall, accountProd)
 	if err != nil {
 		return nil, err
 	}
@@ -260,44 +492,83 @@ func listEC2InstancesByUsername(username string) (*common.InstanceListResponse,
 	return &result, nil
 }
 
-func listEC2InstancesByUsernameForAccount(username string, account string) ([]common.Instance, error) {
+func listEC2InstancesByUsernameForAccount(username string, groups []string, showall bool, account string) ([]common.Instance, error) {
 	instances := []common.Instance{}
-	filters := &ec2.DescribeInstancesInput{
-		Filters: []*ec2.Filter{
+	filters := &ec2.DescribeInstancesInput{}
+	if !showall {
+		// Tag values cannot be filtered case insensitive and exact at the same time.
+		// Get all candidates and check the owner below.
+		filters.Filters = []*ec2.Filter{
 			{
-				Name: aws.String("tag:Owner"),
+				Name: aws.String("tag-key"),
 				Values: []*string{
-					// Case insensitive filter
-					aws.String("*" + strings.ToLower(username) + "*"),
-					aws.String("*" + strings.ToUpper(username) + "*"),
+					aws.String(ownerTag),
+					aws.String(ownerGroupTag),
 				},
 			},
-		},
+		}
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := svc.DescribeInstances(filters)
+	owned := []*ec2.Instance{}
+	err = svc.DescribeInstancesPages(filters, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if showall || isTaggedOwner(instance.Tags, username, groups) {
+					owned = append(owned, instance)
+				}
+			}
+		}
+		return true
+	})
 	if err != nil {
 		log.Print("Unable to list instances (DescribeInstances API call): " + err.Error())
 		return nil, errors.New(ec2ListError)
 	}
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			snapshots, _ := listSnapshots(instance, account)
-			volumes := listVolumes(instance)
-			instances = append(instances, getInstanceStruct(instance, account, snapshots, volumes))
-		}
+	for _, instance := range owned {
+		snapshots, _ := listSnapshots(instance, account, username)
+		volumes := listVolumes(instance)
+		instances = append(instances, getInstanceStruct(instance, account, username, snapshots, volumes))
 	}
 
 	return instances, nil
 }
 
-func listSnapshots(instance *ec2.Instance, account string) ([]*ec2.Snapshot, error) {
-	svc, err := GetEC2ClientForAccount(account)
+// listEC2InstancesWithTags returns all instances in account that have at least one of the tags.
+// This is used by the background jobs and does not check the owner.
+func listEC2InstancesWithTags(account string, tagKeys ...string) ([]*ec2.Instance, error) {
+	svc, err := GetEC2ClientForAccount(account, "")
+	if err != nil {
+		return nil, err
+	}
+	filters := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice(tagKeys),
+			},
+		},
+	}
+	instances := []*ec2.Instance{}
+	err = svc.DescribeInstancesPages(filters, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+		return true
+	})
+	if err != nil {
+		log.Print("Unable to list instances (DescribeInstances API call): " + err.Error())
+		return nil, errors.New(ec2ListError)
+	}
+	return instances, nil
+}
+
+func listSnapshots(instance *ec2.Instance, account string, username string) ([]*ec2.Snapshot, error) {
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		return nil, errors.New(ec2ListError)
 	}
@@ -326,7 +597,7 @@ func listSnapshots(instance *ec2.Instance, account string) ([]*ec2.Snapshot, err
 			// try and get devicename. this works if the original volume
 			// is still attached to an ec2 instance.
 			// If the device name cannot be found return an error to the user
-			devicename, err := getDeviceName(*snapshot.VolumeId, account)
+			devicename, err := getDeviceName(*snapshot.VolumeId, account, username)
 			if err != nil {
 				devicename = "Disk name unknown"
 			}
@@ -350,6 +621,58 @@ func hasTag(tags []*ec2.Tag, name string) bool {
 	return false
 }
 
+// Returns the value of the tag or an empty string if it doesn't exist
+func getTagValue(tags []*ec2.Tag, name string) string {
+	for _, tag := range tags {
+		if *tag.Key == name && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
+
+// isInstanceOwner returns true if the user is a tagged owner of the instance
+// (see isTaggedOwner) or the user is member of the admin group.
+func isInstanceOwner(tags []*ec2.Tag, username string, groups []string) bool {
+	if username == "" {
+		return false
+	}
+	return isEC2Admin(groups) || isTaggedOwner(tags, username, groups)
+}
+
+// isTaggedOwner returns true if the user is in the Owner tag (exact match, multiple
+// users can be separated by comma or space) or the OwnerGroup tag is one of the users groups.
+// The admin group is not considered, so admins only see their own instances in the list.
+func isTaggedOwner(tags []*ec2.Tag, username string, groups []string) bool {
+	if username == "" {
+		return false
+	}
+	owners := strings.FieldsFunc(getTagValue(tags, ownerTag), func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	if common.ContainsStringI(owners, username) {
+		return true
+	}
+	ownerGroup := getTagValue(tags, ownerGroupTag)
+	return ownerGroup != "" && common.ContainsStringI(groups, ownerGroup)
+}
+
+// isEC2Admin returns true if the admin group is configured and one of the groups
+func isEC2Admin(groups []string) bool {
+	adminGroup := config.Config().GetString("aws_ec2_admin_group")
+	return adminGroup != "" && common.ContainsStringI(groups, adminGroup)
+}
+
+// Returns true if the volume is attached to the instance
+func hasVolume(instance *common.Instance, volumeId string) bool {
+	for _, v := range instance.Volumes {
+		if v.VolumeId == volumeId {
+			return true
+		}
+	}
+	return false
+}
+
 func listVolumes(instance *ec2.Instance) []common.Volume {
 	volumes := []common.Volume{}
 	for _, volume := range instance.BlockDeviceMappings {
@@ -365,14 +688,14 @@ func getVolumeStruct(volume *ec2.InstanceBlockDeviceMapping) common.Volume {
 	}
 }
 
-func getDeviceName(volumeId string, account string) (string, error) {
+func getDeviceName(volumeId string, account string, username string) (string, error) {
 	input := &ec2.DescribeVolumesInput{
 		VolumeIds: []*string{
 			aws.String(volumeId),
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return "", errors.New(ec2StartError)
@@ -397,7 +720,7 @@ func appendTag(tags []*ec2.Tag, name string, value string) []*ec2.Tag {
 	return tags
 }
 
-func getTags(resourceid string, account string) ([]*ec2.Tag, error) {
+func getTags(resourceid string, account string, username string) ([]*ec2.Tag, error) {
 	input := &ec2.DescribeTagsInput{
 		Filters: []*ec2.Filter{
 			{
@@ -409,7 +732,7 @@ func getTags(resourceid string, account string) ([]*ec2.Tag, error) {
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, err
@@ -427,14 +750,14 @@ func getTags(resourceid string, account string) ([]*ec2.Tag, error) {
 	return tags, nil
 }
 
-func getImageName(imageId string, account string) (*string, error) {
+func getImageName(imageId string, account string, username string) (*string, error) {
 	input := &ec2.DescribeImagesInput{
 		ImageIds: []*string{
 			aws.String(imageId),
 		},
 	}
 
-	svc, err := GetEC2ClientForAccount(account)
+	svc, err := GetEC2ClientForAccount(account, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, err
@@ -453,7 +776,7 @@ func getImageName(imageId string, account string) (*string, error) {
 	return describeImagesOutput.Images[0].Name, nil
 }
 
-func getInstanceStruct(instance *ec2.Instance, account string, snapshots []*ec2.Snapshot, volumes []common.Volume) common.Instance {
+func getInstanceStruct(instance *ec2.Instance, account string, username string, snapshots []*ec2.Snapshot, volumes []common.Volume) common.Instance {
 	var name string
 	for _, tag := range instance.Tags {
 		if *tag.Key == "Name" {
@@ -461,7 +784,7 @@ func getInstanceStruct(instance *ec2.Instance, account string, snapshots []*ec2.
 			break
 		}
 	}
-	imageName, _ := getImageName(*instance.ImageId, account)
+	imageName, _ := getImageName(*instance.ImageId, account, username)
 
 	// there is no privateIp when the instance has been terminated
 	var privateIpAddress string
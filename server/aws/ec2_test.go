@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestIsInstanceOwner(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("aws_ec2_admin_group", "DG_EC2_ADMINS")
+
+	tags := func(owner, ownerGroup string) []*ec2.Tag {
+		return []*ec2.Tag{
+			{Key: aws.String(ownerTag), Value: aws.String(owner)},
+			{Key: aws.String(ownerGroupTag), Value: aws.String(ownerGroup)},
+		}
+	}
+	var testsets = []struct {
+		tags     []*ec2.Tag
+		username string
+		groups   []string
+		expected bool
+	}{
+		{tags("abc", ""), "abc", nil, true},
+		{tags("ABC", ""), "abc", nil, true},
+		// no substring matches
+		{tags("abcd", ""), "abc", nil, false},
+		{tags("u123456, abc", ""), "abc", nil, true},
+		{tags("u123456 abcd", ""), "abc", nil, false},
+		{tags("xyz", "DG_TEAM"), "abc", []string{"DG_OTHER", "dg_team"}, true},
+		{tags("xyz", "DG_TEAM"), "abc", []string{"DG_OTHER"}, false},
+		{tags("xyz", ""), "abc", []string{""}, false},
+		{tags("xyz", ""), "abc", []string{"DG_EC2_ADMINS"}, true},
+		{tags("", ""), "", nil, false},
+	}
+	for _, set := range testsets {
+		if isInstanceOwner(set.tags, set.username, set.groups) != set.expected {
+			t.Errorf("isInstanceOwner(%v, %v, %v) should be %v", set.tags, set.username, set.groups, set.expected)
+		}
+	}
+}
+
+func TestIsTaggedOwner(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("aws_ec2_admin_group", "DG_EC2_ADMINS")
+
+	tags := []*ec2.Tag{
+		{Key: aws.String(ownerTag), Value: aws.String("abc")},
+		{Key: aws.String(ownerGroupTag), Value: aws.String("DG_TEAM")},
+	}
+	var testsets = []struct {
+		username string
+		groups   []string
+		expected bool
+	}{
+		{"abc", nil, true},
+		{"xyz", []string{"DG_TEAM"}, true},
+		// Admins are only owners of their own instances
+		{"xyz", []string{"DG_EC2_ADMINS"}, false},
+		{"abc", []string{"DG_EC2_ADMINS"}, true},
+		{"", nil, false},
+	}
+	for _, set := range testsets {
+		if isTaggedOwner(tags, set.username, set.groups) != set.expected {
+			t.Errorf("isTaggedOwner(%v, %v) should be %v", set.username, set.groups, set.expected)
+		}
+	}
+}
@@ -49,7 +49,7 @@ func validateNewS3User(username string, bucketname string, newuser string, stage
 		return errors.New("Username can only contain alphanumeric characters and -")
 	}
 
-	svc, err := GetIAMClient(stage)
+	svc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return err
 	}
@@ -77,10 +77,10 @@ func validateNewS3User(username string, bucketname string, newuser string, stage
 	return errors.New("Bucket " + bucketname + " doesn't exist or you're not allowed to create a Bucket")
 }
 
-func createNewS3User(bucketname string, s3username string, stage string, isReadonly bool) (*common.S3CredentialsResponse, error) {
+func createNewS3User(bucketname string, s3username string, stage string, isReadonly bool, username string) (*common.S3CredentialsResponse, error) {
 	generatedName := bucketname + "-" + s3username
 
-	svc, err := GetIAMClient(stage)
+	svc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return nil, err
 	}
@@ -123,20 +123,20 @@ func createNewS3User(bucketname string, s3username string, stage string, isReado
 		policy += bucketWritePolicy
 	}
 
-	err = attachIAMPolicyToUser(policy, generatedName, stage)
+	err = attachIAMPolicyToUser(policy, generatedName, stage, username)
 	if err != nil {
 		log.Print("Error while calling attachIAMPolicyToUser: " + err.Error())
 		return &cred, errors.New(genericUserCreationError)
 	}
 
-	addUserToGroup(generatedName, "S3-Functionuser", stage)
+	addUserToGroup(generatedName, "S3-Functionuser", stage, username)
 
-	password, err := getRandomPassword(stage)
+	password, err := getRandomPassword(stage, username)
 	if err != nil {
 		log.Print("Error while calling addUserToGroup: " + err.Error())
 		return nil, errors.New(genericUserCreationError)
 	}
-	err = createLoginProfile(generatedName, password, stage)
+	err = createLoginProfile(generatedName, password, stage, username)
 	if err != nil {
 		log.Print("Error while calling createLoginProfile: " + err.Error())
 		return nil, errors.New(genericUserCreationError)
@@ -146,8 +146,8 @@ func createNewS3User(bucketname string, s3username string, stage string, isReado
 	return &cred, nil
 }
 
-func addUserToGroup(user, group, stage string) error {
-	svc, err := GetIAMClient(stage)
+func addUserToGroup(user, group, stage, username string) error {
+	svc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return err
 	}
@@ -164,8 +164,8 @@ func addUserToGroup(user, group, stage string) error {
 	return nil
 }
 
-func getRandomPassword(stage string) (*string, error) {
-	svc, err := GetSecretsmanagerClient(stage)
+func getRandomPassword(stage string, username string) (*string, error) {
+	svc, err := GetSecretsmanagerClient(stage, username)
 	if err != nil {
 		return nil, err
 	}
@@ -176,13 +176,13 @@ func getRandomPassword(stage string) (*string, error) {
 	return output.RandomPassword, nil
 }
 
-func createLoginProfile(username string, password *string, stage string) error {
-	svc, err := GetIAMClient(stage)
+func createLoginProfile(iamUser string, password *string, stage string, username string) error {
+	svc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return err
 	}
 	input := &iam.CreateLoginProfileInput{
-		UserName: &username,
+		UserName: &iamUser,
 		Password: password,
 	}
 
@@ -193,25 +193,23 @@ func createLoginProfile(username string, password *string, stage string) error {
 	return nil
 }
 
-func attachIAMPolicyToUser(policyName string, username string, stage string) error {
-	svc, err := GetIAMClient(stage)
+func attachIAMPolicyToUser(policyName string, iamUser string, stage string, username string) error {
+	svc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return err
 	}
 
 	// First, figure out the AWS account ID
-	result, err := svc.GetUser(nil)
-	var accountNumber string
+	// This also works with assumed role credentials (see sts.go)
+	accountNumber, err := getAccountID(stage, username)
 	if err != nil {
-		return errors.New("GetUser error in attachIAMPolicyToUser() while trying to determine account ID: " + err.Error())
+		return errors.New("GetCallerIdentity error in attachIAMPolicyToUser() while trying to determine account ID: " + err.Error())
 	}
-	re := regexp.MustCompile("[0-9]+")
-	accountNumber = re.FindString(*result.User.Arn)
 
 	// Then, attach the policy given to the user
 	input := &iam.AttachUserPolicyInput{
 		PolicyArn: aws.String("arn:aws:iam::" + accountNumber + ":policy/" + policyName),
-		UserName:  aws.String(username),
+		UserName:  aws.String(iamUser),
 	}
 	_, err = svc.AttachUserPolicy(input)
 	if err != nil {
@@ -26,7 +26,7 @@ const (
 	s3ListError   = "Not able to list Buckets. Please open a Jira issue"
 )
 
-func validateNewS3Bucket(projectname string, bucketname string, billing string, stage string) error {
+func validateNewS3Bucket(projectname string, bucketname string, billing string, stage string, username string) error {
 	if len(stage) == 0 {
 		return errors.New("Environment must be defined")
 	}
@@ -49,7 +49,7 @@ func validateNewS3Bucket(projectname string, bucketname string, billing string,
 		return errors.New("Bucketname can only contain alphanumeric characters or -")
 	}
 
-	svc, err := GetS3Client(stage)
+	svc, err := GetS3Client(stage, username)
 	if err != nil {
 		return err
 	}
@@ -95,7 +95,7 @@ func newS3BucketHandler(c *gin.Context) {
 			return
 		}
 
-		if err := validateNewS3Bucket(data.Project, newbucketname, data.Billing, data.Stage); err != nil {
+		if err := validateNewS3Bucket(data.Project, newbucketname, data.Billing, data.Stage, username); err != nil {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 			return
 		}
@@ -143,7 +143,7 @@ func newS3UserHandler(c *gin.Context) {
 
 	log.Print(username + " creates a new user (" + data.UserName + ") for " + bucketName + " , readonly: " + strconv.FormatBool(data.IsReadonly))
 
-	credentials, err := createNewS3User(bucketName, data.UserName, stage, data.IsReadonly)
+	credentials, err := createNewS3User(bucketName, data.UserName, stage, data.IsReadonly, username)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
@@ -159,7 +159,7 @@ func newS3UserHandler(c *gin.Context) {
 }
 
 func createNewS3Bucket(username string, projectname string, bucketname string, billing string, stage string) error {
-	svc, err := GetS3Client(stage)
+	svc, err := GetS3Client(stage, username)
 	if err != nil {
 		return err
 	}
@@ -201,7 +201,7 @@ func createNewS3Bucket(username string, projectname string, bucketname string, b
 	log.Print("Creating IAM policies for bucket " + bucketname + "...")
 
 	// Create a IAM service client.
-	iamSvc, err := GetIAMClient(stage)
+	iamSvc, err := GetIAMClient(stage, username)
 	if err != nil {
 		return err
 	}
@@ -318,7 +318,7 @@ func listS3BucketByUsernameForAccount(username string, account string) ([]common
 		stage = stageDev
 	}
 
-	svc, err := GetS3Client(stage)
+	svc, err := GetS3Client(stage, username)
 	if err != nil {
 		return nil, err
 	}
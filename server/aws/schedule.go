@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	scheduleStartTag    = "ssp_schedule_start"
+	scheduleStopTag     = "ssp_schedule_stop"
+	scheduleTimezoneTag = "ssp_schedule_timezone"
+	// The user who created the schedule. The scheduler acts on behalf of this user.
+	scheduleOwnerTag = "ssp_schedule_owner"
+
+	schedulerInterval = time.Minute
+	ec2ScheduleError  = "The schedule couldn't be saved. Please open a ticket"
+)
+
+func getEC2InstanceScheduleHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, getScheduleFromTags(instance.Tags))
+}
+
+func setEC2InstanceScheduleHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+
+	var data common.EC2ScheduleCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if err := validateSchedule(data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("%v sets schedule of instance %v to %+v", username, instanceid, data)
+	if err := setSchedule(instance, data, username); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: ec2ScheduleError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Schedule has been saved"})
+}
+
+func validateSchedule(schedule common.EC2ScheduleCommand) error {
+	// An empty schedule removes the schedule
+	if schedule.Start == "" && schedule.Stop == "" {
+		return nil
+	}
+	if schedule.Timezone == "" {
+		return errors.New("Timezone must be provided")
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("Invalid timezone: %v", schedule.Timezone)
+	}
+	for _, expr := range []string{schedule.Start, schedule.Stop} {
+		if expr == "" {
+			continue
+		}
+		if _, err := parseSchedule(expr, schedule.Timezone); err != nil {
+			return fmt.Errorf("Invalid cron expression: %v", expr)
+		}
+	}
+	return nil
+}
+
+func parseSchedule(expr string, timezone string) (cron.Schedule, error) {
+	return cron.ParseStandard("CRON_TZ=" + timezone + " " + expr)
+}
+
+func getScheduleFromTags(tags []*ec2.Tag) common.EC2ScheduleCommand {
+	return common.EC2ScheduleCommand{
+		Start:    getTagValue(tags, scheduleStartTag),
+		Stop:     getTagValue(tags, scheduleStopTag),
+		Timezone: getTagValue(tags, scheduleTimezoneTag),
+	}
+}
+
+func setSchedule(instance *common.Instance, schedule common.EC2ScheduleCommand, username string) error {
+	svc, err := GetEC2ClientForAccount(instance.Account, username)
+	if err != nil {
+		return err
+	}
+
+	if schedule.Start == "" && schedule.Stop == "" {
+		_, err = svc.DeleteTags(&ec2.DeleteTagsInput{
+			Resources: []*string{aws.String(instance.InstanceId)},
+			Tags: []*ec2.Tag{
+				{Key: aws.String(scheduleStartTag)},
+				{Key: aws.String(scheduleStopTag)},
+				{Key: aws.String(scheduleTimezoneTag)},
+				{Key: aws.String(scheduleOwnerTag)},
+			},
+		})
+		if err != nil {
+			log.Println("Error deleting schedule (DeleteTags API call): " + err.Error())
+		}
+		return err
+	}
+
+	_, err = svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(instance.InstanceId)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(scheduleStartTag), Value: aws.String(schedule.Start)},
+			{Key: aws.String(scheduleStopTag), Value: aws.String(schedule.Stop)},
+			{Key: aws.String(scheduleTimezoneTag), Value: aws.String(schedule.Timezone)},
+			{Key: aws.String(scheduleOwnerTag), Value: aws.String(username)},
+		},
+	})
+	if err != nil {
+		log.Println("Error saving schedule (CreateTags API call): " + err.Error())
+	}
+	return err
+}
+
+// StartEC2Scheduler starts and stops the EC2 instances according to
+// their schedule tags. It runs in the background until the server stops.
+func StartEC2Scheduler() {
+	if !config.Config().GetBool("aws_ec2_scheduler_enabled") {
+		log.Println("EC2 scheduler is disabled")
+		return
+	}
+	log.Println("Starting EC2 scheduler")
+	go func() {
+		lastRun := time.Now()
+		for now := range time.Tick(schedulerInterval) {
+			runEC2Scheduler(lastRun, now)
+			lastRun = now
+		}
+	}()
+}
+
+func runEC2Scheduler(from time.Time, to time.Time) {
+	// LDAP groups of the owners, only fetched once per run
+	groups := make(map[string][]string)
+	for _, account := range []string{accountNonProd, accountProd} {
+		instances, err := listEC2InstancesWithTags(account, scheduleStartTag, scheduleStopTag)
+		if err != nil {
+			log.Printf("EC2 scheduler: error listing instances in account %v: %v", account, err)
+			continue
+		}
+		for _, instance := range instances {
+			owner := getTagValue(instance.Tags, scheduleOwnerTag)
+			// Ignore the schedule, if the user who created it is not an owner anymore
+			if _, ok := groups[owner]; !ok {
+				groups[owner] = getGroups(owner)
+			}
+			if !isInstanceOwner(instance.Tags, owner, groups[owner]) {
+				log.Printf("EC2 scheduler: %v is not an owner of instance %v. Skipping", owner, *instance.InstanceId)
+				continue
+			}
+			action := getScheduledAction(getScheduleFromTags(instance.Tags), from, to)
+			if err := executeScheduledAction(instance, action, account, owner); err != nil {
+				log.Printf("EC2 scheduler: error executing %v on instance %v: %v", action, *instance.InstanceId, err)
+			}
+		}
+	}
+}
+
+// getScheduledAction returns "start" or "stop" if the schedule fires in the
+// time window (from, to]. If both fire, the later one wins.
+func getScheduledAction(schedule common.EC2ScheduleCommand, from time.Time, to time.Time) string {
+	action := ""
+	var actionTime time.Time
+	for name, expr := range map[string]string{"start": schedule.Start, "stop": schedule.Stop} {
+		if expr == "" {
+			continue
+		}
+		s, err := parseSchedule(expr, schedule.Timezone)
+		if err != nil {
+			continue
+		}
+		next := s.Next(from)
+		if next.After(to) {
+			continue
+		}
+		if action == "" || next.After(actionTime) {
+			action = name
+			actionTime = next
+		}
+	}
+	return action
+}
+
+func executeScheduledAction(instance *ec2.Instance, action string, account string, username string) error {
+	state := *instance.State.Name
+	ids := []*string{instance.InstanceId}
+	switch {
+	case action == "start" && state == ec2.InstanceStateNameStopped:
+		svc, err := GetEC2ClientForAccount(account, username)
+		if err != nil {
+			return err
+		}
+		log.Printf("EC2 scheduler: starting instance %v of %v", *instance.InstanceId, username)
+		_, err = svc.StartInstances(&ec2.StartInstancesInput{InstanceIds: ids})
+		return err
+	case action == "stop" && state == ec2.InstanceStateNameRunning:
+		svc, err := GetEC2ClientForAccount(account, username)
+		if err != nil {
+			return err
+		}
+		log.Printf("EC2 scheduler: stopping instance %v of %v", *instance.InstanceId, username)
+		_, err = svc.StopInstances(&ec2.StopInstancesInput{InstanceIds: ids})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	var testsets = []struct {
+		schedule common.EC2ScheduleCommand
+		valid    bool
+	}{
+		{common.EC2ScheduleCommand{}, true},
+		{common.EC2ScheduleCommand{Start: "0 7 * * 1-5", Stop: "0 19 * * 1-5", Timezone: "Europe/Zurich"}, true},
+		{common.EC2ScheduleCommand{Stop: "0 19 * * *", Timezone: "UTC"}, true},
+		{common.EC2ScheduleCommand{Start: "0 7 * * 1-5"}, false},
+		{common.EC2ScheduleCommand{Start: "0 7 * * 1-5", Timezone: "Mars/Olympus"}, false},
+		{common.EC2ScheduleCommand{Start: "0 25 * * *", Timezone: "UTC"}, false},
+	}
+	for _, set := range testsets {
+		err := validateSchedule(set.schedule)
+		if set.valid && err != nil {
+			t.Errorf("Schedule %+v should be valid, but got: %v", set.schedule, err)
+		}
+		if !set.valid && err == nil {
+			t.Errorf("Schedule %+v should be invalid", set.schedule)
+		}
+	}
+}
+
+func TestGetScheduledAction(t *testing.T) {
+	schedule := common.EC2ScheduleCommand{
+		Start:    "0 7 * * *",
+		Stop:     "0 19 * * *",
+		Timezone: "Europe/Zurich",
+	}
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	var testsets = []struct {
+		from     time.Time
+		expected string
+	}{
+		{time.Date(2020, 8, 3, 6, 59, 30, 0, zurich), "start"},
+		{time.Date(2020, 8, 3, 18, 59, 30, 0, zurich), "stop"},
+		{time.Date(2020, 8, 3, 12, 0, 0, 0, zurich), ""},
+		// 05:00 UTC is 07:00 in Zurich (summer time)
+		{time.Date(2020, 8, 3, 4, 59, 30, 0, time.UTC), "start"},
+	}
+	for _, set := range testsets {
+		action := getScheduledAction(schedule, set.from, set.from.Add(schedulerInterval))
+		if action != set.expected {
+			t.Errorf("Expected action %q from %v, but got %q", set.expected, set.from, action)
+		}
+	}
+}
@@ -5,15 +5,16 @@ import (
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
 
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,23 +49,29 @@ func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/aws/ec2", listEC2InstancesHandler)
 	r.DELETE("/aws/snapshots/:account/:snapshotid", deleteEC2InstanceSnapshotHandler)
 	r.POST("/aws/snapshots", createEC2InstanceSnapshotHandler)
-	r.POST("/aws/ec2/:instanceid/:state", setEC2InstanceStateHandler)
+	r.POST("/aws/snapshots/:snapshotid/restore", restoreEC2InstanceSnapshotHandler)
+	r.POST("/aws/ec2/:instanceid/:state", postEC2InstanceHandler)
+	r.GET("/aws/ec2/:instanceid/console", getEC2InstanceConsoleHandler)
+	r.GET("/aws/ec2/:instanceid/schedule", getEC2InstanceScheduleHandler)
+	r.PUT("/aws/ec2/:instanceid/schedule", setEC2InstanceScheduleHandler)
+	r.GET("/aws/ec2/:instanceid/retention", getEC2InstanceRetentionHandler)
+	r.PUT("/aws/ec2/:instanceid/retention", setEC2InstanceRetentionHandler)
 }
 
-func GetEC2Client(stage string) (*ec2.EC2, error) {
+func GetEC2Client(stage string, username string) (*ec2.EC2, error) {
 	account, err := getAccountForStage(stage)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := getAwsSession(account)
+	sess, err := getAwsSession(account, username)
 	if err != nil {
 		return nil, err
 	}
 	return ec2.New(sess), nil
 }
 
-func GetEC2ClientForAccount(account string) (*ec2.EC2, error) {
+func GetEC2ClientForAccount(account string, username string) (*ec2.EC2, error) {
 	var stage string
 	if account == accountProd {
 		stage = stageProd
@@ -72,7 +79,7 @@ func GetEC2ClientForAccount(account string) (*ec2.EC2, error) {
 		stage = stageDev
 	}
 
-	svc, err := GetEC2Client(stage)
+	svc, err := GetEC2Client(stage, username)
 	if err != nil {
 		log.Println("Error getting EC2 client: " + err.Error())
 		return nil, err
@@ -80,46 +87,61 @@ func GetEC2ClientForAccount(account string) (*ec2.EC2, error) {
 	return svc, nil
 }
 
-func GetS3Client(stage string) (*s3.S3, error) {
+func GetS3Client(stage string, username string) (*s3.S3, error) {
 	account, err := getAccountForStage(stage)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := getAwsSession(account)
+	sess, err := getAwsSession(account, username)
 	if err != nil {
 		return nil, err
 	}
 	return s3.New(sess), nil
 }
 
-func GetIAMClient(stage string) (*iam.IAM, error) {
+func GetIAMClient(stage string, username string) (*iam.IAM, error) {
 	account, err := getAccountForStage(stage)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := getAwsSession(account)
+	sess, err := getAwsSession(account, username)
 	if err != nil {
 		return nil, err
 	}
 	return iam.New(sess), nil
 }
 
-func GetSecretsmanagerClient(stage string) (*secretsmanager.SecretsManager, error) {
+func GetSecretsmanagerClient(stage string, username string) (*secretsmanager.SecretsManager, error) {
 	account, err := getAccountForStage(stage)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := getAwsSession(account)
+	sess, err := getAwsSession(account, username)
 	if err != nil {
 		return nil, err
 	}
 	return secretsmanager.New(sess), nil
 }
 
-func getAwsSession(account string) (*session.Session, error) {
+func GetSTSClient(stage string, username string) (*sts.STS, error) {
+	account, err := getAccountForStage(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := getAwsSession(account, username)
+	if err != nil {
+		return nil, err
+	}
+	return sts.New(sess), nil
+}
+
+// getAwsSession creates a session for the account. The username is passed to
+// AWS as role session name, if assuming roles is configured (see sts.go).
+func getAwsSession(account string, username string) (*session.Session, error) {
 	cfg := config.Config()
 	// Validate necessary env variables
 	region := cfg.GetString("aws_region")
@@ -134,22 +156,13 @@ func getAwsSession(account string) (*session.Session, error) {
 	}
 
 	// Create AWS session based on account
-	var accessKeyID string
-	var accessSecret string
-
-	switch account {
-	case accountProd:
-		accessKeyID = cfg.GetString("aws_prod_access_key_id")
-		accessSecret = cfg.GetString("aws_prod_secret_access_key")
-	case accountNonProd:
-		accessKeyID = cfg.GetString("aws_nonprod_access_key_id")
-		accessSecret = cfg.GetString("aws_nonprod_secret_access_key")
-	default:
-		log.Println("Invalid account: " + account)
+	creds, err := getCredentials(account, username, region)
+	if err != nil {
+		return nil, err
 	}
 
 	sess, err := session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(accessKeyID, accessSecret, ""),
+		Credentials: creds,
 		Region:      aws.String(region)},
 	)
 
@@ -161,6 +174,17 @@ func getAwsSession(account string) (*session.Session, error) {
 	return sess, nil
 }
 
+// getGroups returns the LDAP groups of the user. If LDAP is not available
+// only the Owner tag is used to check the permissions.
+func getGroups(username string) []string {
+	groups, err := ldap.GetGroups(username)
+	if err != nil {
+		log.Println("Error getting ldap groups of " + username + ": " + err.Error())
+		return []string{}
+	}
+	return groups
+}
+
 // getAccountForStage remapps the stage string form the UI to
 // the technical AWS account
 // dev, test, int = NONPROD
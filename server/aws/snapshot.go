@@ -0,0 +1,516 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	retentionDailyTag  = "ssp_snapshot_retention_daily"
+	retentionWeeklyTag = "ssp_snapshot_retention_weekly"
+	// The user who created the retention policy. Snapshots are deleted on behalf of this user.
+	retentionOwnerTag = "ssp_snapshot_retention_owner"
+
+	retentionInterval = time.Hour
+	maxRetention      = 100
+
+	// Status of the last snapshot restore on the instance
+	snapshotRestoreTag = "ssp_snapshot_restore"
+
+	snapshotRetentionError = "The retention policy couldn't be saved. Please open a ticket"
+)
+
+var (
+	restoresMutex sync.Mutex
+	// Instances with a running restore. Only one swap per instance at a time.
+	runningRestores = map[string]bool{}
+)
+
+func restoreEC2InstanceSnapshotHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	snapshotid := c.Param("snapshotid")
+
+	instance, snapshot, err := getSnapshot(snapshotid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	deviceName, oldVolumeId, err := getRestoreVolume(instance, snapshot)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if !startRestore(instance.InstanceId) {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("A snapshot of instance %v is already being restored", instance.InstanceId)})
+		return
+	}
+
+	log.Printf("%v restores snapshot %v on instance %v", username, snapshotid, instance.InstanceId)
+	// The swap takes minutes, the result is saved in the restore tag of the instance
+	go func() {
+		defer finishRestore(instance.InstanceId)
+		setRestoreStatus(instance, username, "running: "+snapshotid)
+		if err := restoreSnapshot(instance, snapshot, deviceName, oldVolumeId, username); err != nil {
+			log.Printf("Error restoring snapshot %v: %v", snapshotid, err)
+			setRestoreStatus(instance, username, "failed: "+snapshotid)
+			return
+		}
+		setRestoreStatus(instance, username, fmt.Sprintf("restored: %v, previous volume: %v", snapshotid, oldVolumeId))
+	}()
+	c.JSON(http.StatusAccepted, common.ApiResponse{
+		Message: fmt.Sprintf("The restore of snapshot %v has been started. The instance is stopped during the restore. "+
+			"The tag %v shows the result, the previous volume %v is detached and can be deleted afterwards.", snapshotid, snapshotRestoreTag, oldVolumeId),
+	})
+}
+
+// getSnapshot returns the snapshot and its instance, if the user is an owner of the instance
+func getSnapshot(snapshotid string, username string) (*common.Instance, *ec2.Snapshot, error) {
+	filters := &ec2.DescribeSnapshotsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name: aws.String("snapshot-id"),
+				Values: []*string{
+					aws.String(snapshotid),
+				},
+			},
+		},
+	}
+	for _, account := range []string{accountNonProd, accountProd} {
+		svc, err := GetEC2ClientForAccount(account, username)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := svc.DescribeSnapshots(filters)
+		if err != nil {
+			log.Println("Error getting snapshot (DescribeSnapshots API call): " + err.Error())
+			return nil, nil, errors.New(genericAwsAPIError)
+		}
+		if len(result.Snapshots) == 0 {
+			continue
+		}
+		instanceid := getTagValue(result.Snapshots[0].Tags, "instance_id")
+		if instanceid == "" {
+			break
+		}
+		instance, err := getInstance(instanceid, username)
+		if err != nil {
+			break
+		}
+		// Use the snapshot of the instance, because it contains the devicename
+		for _, snapshot := range instance.Snapshots {
+			if *snapshot.SnapshotId == snapshotid {
+				return instance, snapshot, nil
+			}
+		}
+	}
+	log.Println("Could not find a snapshot with id: " + snapshotid)
+	return nil, nil, errors.New("Snapshot " + snapshotid + " doesn't exist or you're not an owner of the instance")
+}
+
+// getRestoreVolume returns the device of the snapshot and the volume, which is currently attached on it
+func getRestoreVolume(instance *common.Instance, snapshot *ec2.Snapshot) (string, string, error) {
+	deviceName := getTagValue(snapshot.Tags, "devicename")
+	for _, v := range instance.Volumes {
+		if deviceName != "" && v.DeviceName == deviceName {
+			return deviceName, v.VolumeId, nil
+		}
+	}
+	return "", "", fmt.Errorf("No volume attached on device %v of instance %v", deviceName, instance.InstanceId)
+}
+
+// startRestore returns false, if there is already a restore running on the instance
+func startRestore(instanceid string) bool {
+	restoresMutex.Lock()
+	defer restoresMutex.Unlock()
+
+	if runningRestores[instanceid] {
+		return false
+	}
+	runningRestores[instanceid] = true
+	return true
+}
+
+func finishRestore(instanceid string) {
+	restoresMutex.Lock()
+	defer restoresMutex.Unlock()
+
+	delete(runningRestores, instanceid)
+}
+
+func setRestoreStatus(instance *common.Instance, username string, status string) {
+	svc, err := GetEC2ClientForAccount(instance.Account, username)
+	if err != nil {
+		log.Printf("Error saving restore status of instance %v: %v", instance.InstanceId, err)
+		return
+	}
+	_, err = svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(instance.InstanceId)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(snapshotRestoreTag), Value: aws.String(status)},
+		},
+	})
+	if err != nil {
+		log.Println("Error saving restore status (CreateTags API call): " + err.Error())
+	}
+}
+
+// restoreProgress contains the steps of a restore, which are rolled back on an error
+type restoreProgress struct {
+	newVolumeId string
+	stopped     bool
+	oldDetached bool
+	newAttached bool
+}
+
+// restoreSnapshot creates a new volume from the snapshot and replaces the volume
+// on the original device. The instance is stopped during the swap and started
+// again afterwards, if it was running. The previous volume is not deleted.
+// On an error the original volume is attached again, the new volume is deleted
+// and the instance is started again.
+func restoreSnapshot(instance *common.Instance, snapshot *ec2.Snapshot, deviceName string, oldVolumeId string, username string) (err error) {
+	svc, err := GetEC2ClientForAccount(instance.Account, username)
+	if err != nil {
+		return err
+	}
+
+	progress := restoreProgress{}
+	defer func() {
+		if err != nil {
+			rollbackRestore(svc, instance, username, deviceName, oldVolumeId, progress)
+		}
+	}()
+
+	volumes, err := svc.DescribeVolumes(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{aws.String(oldVolumeId)},
+	})
+	if err != nil {
+		return err
+	}
+	oldVolume := volumes.Volumes[0]
+
+	newVolume, err := svc.CreateVolume(&ec2.CreateVolumeInput{
+		SnapshotId:       snapshot.SnapshotId,
+		AvailabilityZone: oldVolume.AvailabilityZone,
+		VolumeType:       oldVolume.VolumeType,
+		Iops:             oldVolume.Iops,
+		Encrypted:        oldVolume.Encrypted,
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String("volume"),
+				Tags:         removeAWSTags(oldVolume.Tags),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	progress.newVolumeId = *newVolume.VolumeId
+	log.Printf("Created volume %v from snapshot %v", *newVolume.VolumeId, *snapshot.SnapshotId)
+	err = svc.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{newVolume.VolumeId},
+	})
+	if err != nil {
+		return err
+	}
+
+	if instance.State != ec2.InstanceStateNameStopped {
+		// Start it again on an error, even if stopping failed half way
+		progress.stopped = instance.State == ec2.InstanceStateNameRunning
+		if _, err := stopEC2Instance(instance.InstanceId, username, instance.Account); err != nil {
+			return err
+		}
+	}
+
+	_, err = svc.DetachVolume(&ec2.DetachVolumeInput{
+		InstanceId: aws.String(instance.InstanceId),
+		VolumeId:   aws.String(oldVolumeId),
+	})
+	if err != nil {
+		return err
+	}
+	progress.oldDetached = true
+	err = svc.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{aws.String(oldVolumeId)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.AttachVolume(&ec2.AttachVolumeInput{
+		Device:     aws.String(deviceName),
+		InstanceId: aws.String(instance.InstanceId),
+		VolumeId:   newVolume.VolumeId,
+	})
+	if err != nil {
+		return err
+	}
+	progress.newAttached = true
+	err = svc.WaitUntilVolumeInUse(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{newVolume.VolumeId},
+	})
+	if err != nil {
+		return err
+	}
+
+	if progress.stopped {
+		if _, err := startEC2Instance(instance.InstanceId, username, instance.Account); err != nil {
+			// The new volume is attached, only the start is repeated by the rollback
+			progress = restoreProgress{stopped: true}
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackRestore brings the instance back to the state before the restore.
+// Errors are logged and the remaining steps are still executed.
+func rollbackRestore(svc *ec2.EC2, instance *common.Instance, username string, deviceName string, oldVolumeId string, progress restoreProgress) {
+	log.Printf("Rolling back the snapshot restore on instance %v", instance.InstanceId)
+	if progress.newAttached {
+		_, err := svc.DetachVolume(&ec2.DetachVolumeInput{
+			InstanceId: aws.String(instance.InstanceId),
+			VolumeId:   aws.String(progress.newVolumeId),
+		})
+		if err == nil {
+			err = svc.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{
+				VolumeIds: []*string{aws.String(progress.newVolumeId)},
+			})
+		}
+		if err != nil {
+			log.Printf("Rollback: error detaching volume %v: %v", progress.newVolumeId, err)
+		}
+	}
+	if progress.oldDetached {
+		_, err := svc.AttachVolume(&ec2.AttachVolumeInput{
+			Device:     aws.String(deviceName),
+			InstanceId: aws.String(instance.InstanceId),
+			VolumeId:   aws.String(oldVolumeId),
+		})
+		if err == nil {
+			err = svc.WaitUntilVolumeInUse(&ec2.DescribeVolumesInput{
+				VolumeIds: []*string{aws.String(oldVolumeId)},
+			})
+		}
+		if err != nil {
+			log.Printf("Rollback: error attaching volume %v on %v: %v", oldVolumeId, deviceName, err)
+		}
+	}
+	if progress.newVolumeId != "" {
+		_, err := svc.DeleteVolume(&ec2.DeleteVolumeInput{
+			VolumeId: aws.String(progress.newVolumeId),
+		})
+		if err != nil {
+			log.Printf("Rollback: error deleting volume %v: %v", progress.newVolumeId, err)
+		}
+	}
+	if progress.stopped {
+		if _, err := startEC2Instance(instance.InstanceId, username, instance.Account); err != nil {
+			log.Printf("Rollback: error starting instance %v: %v", instance.InstanceId, err)
+		}
+	}
+}
+
+// removeAWSTags removes the tags with the reserved prefix "aws:", they cannot be set by users
+func removeAWSTags(tags []*ec2.Tag) []*ec2.Tag {
+	result := []*ec2.Tag{}
+	for _, tag := range tags {
+		if !strings.HasPrefix(*tag.Key, "aws:") {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func getEC2InstanceRetentionHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, getRetentionFromTags(instance.Tags))
+}
+
+func setEC2InstanceRetentionHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	instanceid := c.Param("instanceid")
+
+	var data common.SnapshotRetentionCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if data.Daily < 0 || data.Weekly < 0 || data.Daily > maxRetention || data.Weekly > maxRetention {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("The number of snapshots must be between 0 and %v", maxRetention)})
+		return
+	}
+	instance, err := getInstance(instanceid, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("%v sets snapshot retention of instance %v to %+v", username, instanceid, data)
+	if err := setRetention(instance, data, username); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: snapshotRetentionError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Retention policy has been saved"})
+}
+
+func getRetentionFromTags(tags []*ec2.Tag) common.SnapshotRetentionCommand {
+	daily, _ := strconv.Atoi(getTagValue(tags, retentionDailyTag))
+	weekly, _ := strconv.Atoi(getTagValue(tags, retentionWeeklyTag))
+	return common.SnapshotRetentionCommand{
+		Daily:  daily,
+		Weekly: weekly,
+	}
+}
+
+func setRetention(instance *common.Instance, retention common.SnapshotRetentionCommand, username string) error {
+	svc, err := GetEC2ClientForAccount(instance.Account, username)
+	if err != nil {
+		return err
+	}
+
+	// A policy without snapshots to keep removes the policy
+	if retention.Daily == 0 && retention.Weekly == 0 {
+		_, err = svc.DeleteTags(&ec2.DeleteTagsInput{
+			Resources: []*string{aws.String(instance.InstanceId)},
+			Tags: []*ec2.Tag{
+				{Key: aws.String(retentionDailyTag)},
+				{Key: aws.String(retentionWeeklyTag)},
+				{Key: aws.String(retentionOwnerTag)},
+			},
+		})
+		if err != nil {
+			log.Println("Error deleting retention policy (DeleteTags API call): " + err.Error())
+		}
+		return err
+	}
+
+	_, err = svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(instance.InstanceId)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(retentionDailyTag), Value: aws.String(strconv.Itoa(retention.Daily))},
+			{Key: aws.String(retentionWeeklyTag), Value: aws.String(strconv.Itoa(retention.Weekly))},
+			{Key: aws.String(retentionOwnerTag), Value: aws.String(username)},
+		},
+	})
+	if err != nil {
+		log.Println("Error saving retention policy (CreateTags API call): " + err.Error())
+	}
+	return err
+}
+
+// StartSnapshotRetention deletes expired snapshots of all instances with a
+// retention policy. It runs in the background until the server stops.
+func StartSnapshotRetention() {
+	if !config.Config().GetBool("aws_snapshot_retention_enabled") {
+		log.Println("EC2 snapshot retention is disabled")
+		return
+	}
+	log.Println("Starting EC2 snapshot retention")
+	go func() {
+		for now := range time.Tick(retentionInterval) {
+			runSnapshotRetention(now)
+		}
+	}()
+}
+
+func runSnapshotRetention(now time.Time) {
+	// LDAP groups of the owners, only fetched once per run
+	groups := make(map[string][]string)
+	for _, account := range []string{accountNonProd, accountProd} {
+		instances, err := listEC2InstancesWithTags(account, retentionDailyTag, retentionWeeklyTag)
+		if err != nil {
+			log.Printf("Snapshot retention: error listing instances in account %v: %v", account, err)
+			continue
+		}
+		for _, instance := range instances {
+			owner := getTagValue(instance.Tags, retentionOwnerTag)
+			// Ignore the policy, if the user who created it is not an owner anymore
+			if _, ok := groups[owner]; !ok {
+				groups[owner] = getGroups(owner)
+			}
+			if !isInstanceOwner(instance.Tags, owner, groups[owner]) {
+				log.Printf("Snapshot retention: %v is not an owner of instance %v. Skipping", owner, *instance.InstanceId)
+				continue
+			}
+			snapshots, err := listSnapshots(instance, account, owner)
+			if err != nil {
+				log.Printf("Snapshot retention: error listing snapshots of instance %v: %v", *instance.InstanceId, err)
+				continue
+			}
+			retention := getRetentionFromTags(instance.Tags)
+			for _, snapshot := range getExpiredSnapshots(snapshots, retention, now) {
+				log.Printf("Snapshot retention: deleting snapshot %v of instance %v", *snapshot.SnapshotId, *instance.InstanceId)
+				if err := deleteSnapshot(*snapshot.SnapshotId, account, owner); err != nil {
+					log.Printf("Snapshot retention: error deleting snapshot %v: %v", *snapshot.SnapshotId, err)
+				}
+			}
+		}
+	}
+}
+
+// getExpiredSnapshots returns the snapshots that are not kept by the retention policy.
+// For each of the last n days (and weeks) the newest snapshot is kept.
+// Snapshots that are not completed yet are never expired.
+func getExpiredSnapshots(snapshots []*ec2.Snapshot, retention common.SnapshotRetentionCommand, now time.Time) []*ec2.Snapshot {
+	expired := []*ec2.Snapshot{}
+	if retention.Daily <= 0 && retention.Weekly <= 0 {
+		return expired
+	}
+
+	sorted := make([]*ec2.Snapshot, len(snapshots))
+	copy(sorted, snapshots)
+	// Newest first
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.After(*sorted[j].StartTime)
+	})
+
+	today := truncateToDay(now.UTC())
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	keptDays := make(map[time.Time]bool)
+	keptWeeks := make(map[time.Time]bool)
+	for _, snapshot := range sorted {
+		if snapshot.State == nil || *snapshot.State != ec2.SnapshotStateCompleted {
+			continue
+		}
+		day := truncateToDay(snapshot.StartTime.UTC())
+		week := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+
+		keep := false
+		if !keptDays[day] && day.After(today.AddDate(0, 0, -retention.Daily)) {
+			keptDays[day] = true
+			keep = true
+		}
+		if !keptWeeks[week] && week.After(monday.AddDate(0, 0, -7*retention.Weekly)) {
+			keptWeeks[week] = true
+			keep = true
+		}
+		if !keep {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
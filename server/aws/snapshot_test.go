@@ -0,0 +1,103 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestGetExpiredSnapshots(t *testing.T) {
+	// Wednesday
+	now := time.Date(2020, 8, 5, 12, 0, 0, 0, time.UTC)
+	snapshot := func(id string, t time.Time, state string) *ec2.Snapshot {
+		return &ec2.Snapshot{SnapshotId: aws.String(id), StartTime: aws.Time(t), State: aws.String(state)}
+	}
+	snapshots := []*ec2.Snapshot{
+		snapshot("today-1", now.Add(-1*time.Hour), ec2.SnapshotStateCompleted),
+		snapshot("today-2", now.Add(-2*time.Hour), ec2.SnapshotStateCompleted),
+		snapshot("yesterday", now.AddDate(0, 0, -1), ec2.SnapshotStateCompleted),
+		snapshot("last-week", now.AddDate(0, 0, -7), ec2.SnapshotStateCompleted),
+		snapshot("two-weeks-ago", now.AddDate(0, 0, -14), ec2.SnapshotStateCompleted),
+		snapshot("pending", now.AddDate(0, 0, -20), ec2.SnapshotStatePending),
+	}
+
+	var testsets = []struct {
+		retention common.SnapshotRetentionCommand
+		expired   []string
+	}{
+		{common.SnapshotRetentionCommand{}, []string{}},
+		{common.SnapshotRetentionCommand{Daily: 1}, []string{"today-2", "yesterday", "last-week", "two-weeks-ago"}},
+		{common.SnapshotRetentionCommand{Daily: 2}, []string{"today-2", "last-week", "two-weeks-ago"}},
+		{common.SnapshotRetentionCommand{Weekly: 2}, []string{"today-2", "yesterday", "two-weeks-ago"}},
+		{common.SnapshotRetentionCommand{Daily: 2, Weekly: 3}, []string{"today-2"}},
+	}
+	for _, set := range testsets {
+		expired := getExpiredSnapshots(snapshots, set.retention, now)
+		ids := []string{}
+		for _, s := range expired {
+			ids = append(ids, *s.SnapshotId)
+		}
+		if len(ids) != len(set.expired) {
+			t.Errorf("Retention %+v: expected expired snapshots %v, but got %v", set.retention, set.expired, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != set.expired[i] {
+				t.Errorf("Retention %+v: expected expired snapshots %v, but got %v", set.retention, set.expired, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestGetRestoreVolume(t *testing.T) {
+	instance := &common.Instance{
+		InstanceId: "i-1",
+		Volumes: []common.Volume{
+			{DeviceName: "/dev/sda1", VolumeId: "vol-root"},
+			{DeviceName: "/dev/sdb", VolumeId: "vol-data"},
+		},
+	}
+	snapshot := func(device string) *ec2.Snapshot {
+		return &ec2.Snapshot{Tags: []*ec2.Tag{{Key: aws.String("devicename"), Value: aws.String(device)}}}
+	}
+
+	var testsets = []struct {
+		snapshot *ec2.Snapshot
+		volumeId string
+		err      bool
+	}{
+		{snapshot("/dev/sdb"), "vol-data", false},
+		{snapshot("/dev/sda1"), "vol-root", false},
+		{snapshot("/dev/sdc"), "", true},
+		{&ec2.Snapshot{}, "", true},
+	}
+
+	for _, tc := range testsets {
+		_, volumeId, err := getRestoreVolume(instance, tc.snapshot)
+		if volumeId != tc.volumeId || (err != nil) != tc.err {
+			t.Errorf("ERROR! Expected %v (error: %v), got %v (%v)", tc.volumeId, tc.err, volumeId, err)
+		}
+	}
+}
+
+func TestStartRestore(t *testing.T) {
+	if !startRestore("i-1") {
+		t.Errorf("ERROR! The first restore must start")
+	}
+	if startRestore("i-1") {
+		t.Errorf("ERROR! A second restore on the same instance must not start")
+	}
+	if !startRestore("i-2") {
+		t.Errorf("ERROR! A restore on another instance must start")
+	}
+	finishRestore("i-1")
+	finishRestore("i-2")
+	if !startRestore("i-1") {
+		t.Errorf("ERROR! A restore must start after the previous one has finished")
+	}
+	finishRestore("i-1")
+}
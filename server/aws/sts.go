@@ -0,0 +1,166 @@
+package aws
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+const (
+	// Used as session name and tag value when there is no portal user,
+	// e.g. for background jobs
+	systemSessionName = "ssp-backend"
+	sessionTagUser    = "ssp-user"
+	// Credentials are refreshed this long before they expire
+	credentialsExpiryWindow = time.Minute
+)
+
+// Cache for the assumed role credentials of every account and user.
+// The credentials.Credentials object takes care of the refresh.
+var credentialsCache = struct {
+	sync.Mutex
+	base    map[string]*credentials.Credentials
+	assumed map[string]*credentials.Credentials
+}{
+	base:    make(map[string]*credentials.Credentials),
+	assumed: make(map[string]*credentials.Credentials),
+}
+
+// getCredentials returns the credentials used to call the AWS API in account.
+// If a role ARN is configured for the account, the base identity assumes
+// this role with the username as session name and session tag, so that
+// CloudTrail shows which portal user triggered the action.
+// Otherwise the static access keys of the account are used.
+func getCredentials(account string, username string, region string) (*credentials.Credentials, error) {
+	roleArn := config.Config().GetString("aws_" + account + "_role_arn")
+	if roleArn == "" {
+		return getBaseCredentials(account, region)
+	}
+
+	sessionName := getRoleSessionName(username)
+	cacheKey := account + "/" + sessionName
+
+	credentialsCache.Lock()
+	defer credentialsCache.Unlock()
+	if creds, ok := credentialsCache.assumed[cacheKey]; ok {
+		return creds, nil
+	}
+
+	base, err := getBaseCredentialsLocked(account, region)
+	if err != nil {
+		return nil, err
+	}
+	baseSess, err := session.NewSession(&aws.Config{
+		Credentials: base,
+		Region:      aws.String(region),
+	})
+	if err != nil {
+		log.Println("Error creating aws base session: ", err.Error())
+		return nil, errors.New(genericAwsAPIError)
+	}
+
+	duration := config.Config().GetDuration("aws_role_session_duration")
+	creds := stscreds.NewCredentials(baseSess, roleArn, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = sessionName
+		p.ExpiryWindow = credentialsExpiryWindow
+		if duration > 0 {
+			p.Duration = duration
+		}
+		p.Tags = []*sts.Tag{
+			{Key: aws.String(sessionTagUser), Value: aws.String(sessionName)},
+		}
+	})
+	credentialsCache.assumed[cacheKey] = creds
+	return creds, nil
+}
+
+func getBaseCredentials(account string, region string) (*credentials.Credentials, error) {
+	credentialsCache.Lock()
+	defer credentialsCache.Unlock()
+	return getBaseCredentialsLocked(account, region)
+}
+
+// getBaseCredentialsLocked returns the identity of the backend itself.
+// This is either a web identity (projected service account token) or
+// the static access keys of the account.
+// credentialsCache must be locked by the caller.
+func getBaseCredentialsLocked(account string, region string) (*credentials.Credentials, error) {
+	if creds, ok := credentialsCache.base[account]; ok {
+		return creds, nil
+	}
+	cfg := config.Config()
+
+	var creds *credentials.Credentials
+	tokenFile := cfg.GetString("aws_web_identity_token_file")
+	if tokenFile != "" {
+		webIdentityRoleArn := cfg.GetString("aws_web_identity_role_arn")
+		if webIdentityRoleArn == "" {
+			log.Println("WARNING: Env variable 'AWS_WEB_IDENTITY_ROLE_ARN' must be specified")
+			return nil, errors.New(common.ConfigNotSetError)
+		}
+		sess, err := session.NewSession(&aws.Config{
+			Credentials: credentials.AnonymousCredentials,
+			Region:      aws.String(region),
+		})
+		if err != nil {
+			log.Println("Error creating aws web identity session: ", err.Error())
+			return nil, errors.New(genericAwsAPIError)
+		}
+		creds = stscreds.NewWebIdentityCredentials(sess, webIdentityRoleArn, systemSessionName, tokenFile)
+	} else {
+		var accessKeyID string
+		var accessSecret string
+
+		switch account {
+		case accountProd:
+			accessKeyID = cfg.GetString("aws_prod_access_key_id")
+			accessSecret = cfg.GetString("aws_prod_secret_access_key")
+		case accountNonProd:
+			accessKeyID = cfg.GetString("aws_nonprod_access_key_id")
+			accessSecret = cfg.GetString("aws_nonprod_secret_access_key")
+		default:
+			log.Println("Invalid account: " + account)
+			return nil, errors.New(wrongAPIUsageError)
+		}
+		creds = credentials.NewStaticCredentials(accessKeyID, accessSecret, "")
+	}
+	credentialsCache.base[account] = creds
+	return creds, nil
+}
+
+// getRoleSessionName converts the username to a valid role session name
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+func getRoleSessionName(username string) string {
+	name := regexp.MustCompile(`[^\w+=,.@-]`).ReplaceAllString(username, "_")
+	if len(name) < 2 {
+		return systemSessionName
+	}
+	if len(name) > 64 {
+		return name[:64]
+	}
+	return name
+}
+
+// getAccountID returns the AWS account number of the current identity
+func getAccountID(stage string, username string) (string, error) {
+	svc, err := GetSTSClient(stage, username)
+	if err != nil {
+		return "", err
+	}
+	result, err := svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return *result.Account, nil
+}
@@ -5,6 +5,9 @@ import "github.com/aws/aws-sdk-go/service/ec2"
 
 const ConfigNotSetError = "This feature hasn't been configured correctly. Please contact the CLP Team"
 
+// WarningHeader is set, if a response is incomplete, e.g. a list after a timeout
+const WarningHeader = "X-Warning"
+
 type ProjectName struct {
 	Project string `json:"project"`
 }
@@ -61,6 +64,7 @@ type AddProjectAdminCommand struct {
 
 type CreateLogseneAppCommand struct {
 	AppName      string `json:"appName"`
+	AppType      string `json:"appType"`
 	DiscountCode string `json:"discountCode"`
 	EditSematextPlanCommand
 	UpdateProjectInformationCommand
@@ -96,6 +100,27 @@ type CreateSnapshotCommand struct {
 	Account     string `json:"account"`
 }
 
+type EC2InstanceTypeCommand struct {
+	InstanceType string `json:"instanceType"`
+}
+
+type EC2ConsoleOutputResponse struct {
+	InstanceId string     `json:"instanceId"`
+	Output     string     `json:"output"`
+	Timestamp  *time.Time `json:"timestamp"`
+}
+
+type SnapshotRetentionCommand struct {
+	Daily  int `json:"daily"`
+	Weekly int `json:"weekly"`
+}
+
+type EC2ScheduleCommand struct {
+	Start    string `json:"start"`
+	Stop     string `json:"stop"`
+	Timezone string `json:"timezone"`
+}
+
 type WorkflowCommand struct {
 	UserInputValues []WorkflowKeyValue `json:"userInputValues"`
 }
@@ -190,6 +215,7 @@ type AdminList struct {
 type SematextAppList struct {
 	AppId         int     `json:"appId"`
 	Name          string  `json:"name"`
+	AppType       string  `json:"appType"`
 	PlanName      string  `json:"planName"`
 	UserRole      string  `json:"userRole"`
 	IsFree        bool    `json:"isFree"`
@@ -197,6 +223,41 @@ type SematextAppList struct {
 	BillingInfo   string  `json:"billingInfo"`
 }
 
+type SematextAppUser struct {
+	Mail   string `json:"mail"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+}
+
+type SematextDailyUsage struct {
+	Date         string  `json:"date"`
+	VolumeMb     float64 `json:"volumeMb"`
+	LimitMb      float64 `json:"limitMb"`
+	LimitReached bool    `json:"limitReached"`
+}
+
+type SematextAppUsage struct {
+	AppId              int                  `json:"appId"`
+	Days               int                  `json:"days"`
+	Usage              []SematextDailyUsage `json:"usage"`
+	LimitReachedDays   int                  `json:"limitReachedDays"`
+	CurrentLimitMb     float64              `json:"currentLimitMb"`
+	RecommendedLimitMb int                  `json:"recommendedLimitMb"`
+	Recommendation     string               `json:"recommendation"`
+}
+
+type SematextChargeback struct {
+	Billing       string   `json:"billing"`
+	Project       string   `json:"project"`
+	Apps          []string `json:"apps"`
+	PricePerMonth float64  `json:"pricePerMonth"`
+}
+
+type InviteSematextUserCommand struct {
+	Mail string `json:"mail"`
+	Role string `json:"role"`
+}
+
 type SematextLogsenePlan struct {
 	PlanId                     int     `json:"planId"`
 	Name                       string  `json:"name"`
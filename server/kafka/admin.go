@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Topic is a Kafka topic with the ownership tags of the SSP
+type Topic struct {
+	Name           string `json:"name"`
+	Partitions     int    `json:"partitions"`
+	RetentionHours int    `json:"retentionHours"`
+	Owner          string `json:"owner"`
+	Billing        string `json:"billing"`
+	Project        string `json:"project"`
+}
+
+// ACL allows a principal (e.g. User:svc-app) an operation on a topic
+type ACL struct {
+	Topic     string `json:"topic"`
+	Principal string `json:"principal"`
+	Operation string `json:"operation"`
+}
+
+// adminClient manages the topics and ACLs of the Kafka cluster.
+// The client stores the tags (owner, billing, project) of the topics durably.
+type adminClient interface {
+	ListTopics() ([]Topic, error)
+	CreateTopic(topic Topic) error
+	UpdateTopicTags(name string, billing string, project string) error
+	DeleteTopic(name string) error
+	ListACLs(topic string) ([]ACL, error)
+	CreateACL(acl ACL) error
+	DeleteACL(acl ACL) error
+}
+
+// adminClients contains the clients, that can be used in the config file (kafka.admin_client).
+// To add a new client: implement the adminClient interface and add it here.
+var adminClients = map[string]func(cfg KafkaConfig) (adminClient, error){
+	"restproxy": newRestProxyAdminClient,
+}
+
+var (
+	clientMutex sync.Mutex
+	// Created on first use. Can be replaced in tests
+	client adminClient
+)
+
+func getAdminClient() (adminClient, error) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	if client != nil {
+		return client, nil
+	}
+	kafkaConfig := getKafkaConfig()
+	factory, ok := adminClients[kafkaConfig.AdminClient]
+	if !ok {
+		return nil, fmt.Errorf("Kafka admin client %q doesn't exist. Check the configuration", kafkaConfig.AdminClient)
+	}
+	c, err := factory(kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	client = c
+	return client, nil
+}
@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func TestGetAdminClient(t *testing.T) {
+	defer func() { client = nil }()
+
+	config.Init("bla")
+	config.Config().Set("kafka.admin_client", "unknown")
+	client = nil
+	if _, err := getAdminClient(); err == nil {
+		t.Errorf("ERROR! expected error for unknown admin client")
+	}
+
+	config.Config().Set("kafka.admin_client", "restproxy")
+	if _, err := getAdminClient(); err == nil {
+		t.Errorf("ERROR! expected error for missing rest_proxy_url")
+	}
+
+	adminClients["memory"] = newMemoryAdminClient
+	defer delete(adminClients, "memory")
+	config.Config().Set("kafka.admin_client", "memory")
+	c1, err := getAdminClient()
+	if err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	c2, _ := getAdminClient()
+	if c1 != c2 {
+		t.Errorf("ERROR! expected the same client")
+	}
+}
+
+func TestMemoryAdminClient(t *testing.T) {
+	c, _ := newMemoryAdminClient(KafkaConfig{})
+
+	if err := c.CreateTopic(Topic{Name: "b"}); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	c.CreateTopic(Topic{Name: "a"})
+	if err := c.CreateTopic(Topic{Name: "a"}); err == nil {
+		t.Errorf("ERROR! expected error for existing topic")
+	}
+	if err := c.UpdateTopicTags("a", "1234", "project"); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	topics, _ := c.ListTopics()
+	expected := []Topic{{Name: "a", Billing: "1234", Project: "project"}, {Name: "b"}}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Errorf("ERROR! expected %v, got %v", expected, topics)
+	}
+
+	acl := ACL{Topic: "a", Principal: "User:svc", Operation: "READ"}
+	c.CreateACL(acl)
+	c.CreateACL(acl)
+	if acls, _ := c.ListACLs("a"); len(acls) != 1 {
+		t.Errorf("ERROR! expected one ACL, got %v", acls)
+	}
+	if err := c.DeleteACL(acl); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	if err := c.DeleteACL(acl); err == nil {
+		t.Errorf("ERROR! expected error for deleted ACL")
+	}
+
+	if err := c.DeleteTopic("a"); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	if err := c.DeleteTopic("a"); err == nil {
+		t.Errorf("ERROR! expected error for deleted topic")
+	}
+}
+
+// memoryAdminClient keeps the topics and ACLs in memory. It is used in the tests.
+type memoryAdminClient struct {
+	mutex  sync.Mutex
+	topics map[string]Topic
+	acls   []ACL
+}
+
+func newMemoryAdminClient(cfg KafkaConfig) (adminClient, error) {
+	return &memoryAdminClient{topics: map[string]Topic{}}, nil
+}
+
+func (m *memoryAdminClient) ListTopics() ([]Topic, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topics := []Topic{}
+	for _, t := range m.topics {
+		topics = append(topics, t)
+	}
+	// map order is random
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].Name < topics[j].Name
+	})
+	return topics, nil
+}
+
+func (m *memoryAdminClient) CreateTopic(topic Topic) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.topics[topic.Name]; ok {
+		return fmt.Errorf("Topic %v already exists", topic.Name)
+	}
+	m.topics[topic.Name] = topic
+	return nil
+}
+
+func (m *memoryAdminClient) UpdateTopicTags(name string, billing string, project string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topic, ok := m.topics[name]
+	if !ok {
+		return fmt.Errorf("Topic %v doesn't exist", name)
+	}
+	topic.Billing = billing
+	topic.Project = project
+	m.topics[name] = topic
+	return nil
+}
+
+func (m *memoryAdminClient) DeleteTopic(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.topics[name]; !ok {
+		return fmt.Errorf("Topic %v doesn't exist", name)
+	}
+	delete(m.topics, name)
+	// Like Kafka, the ACLs of the topic are kept
+	return nil
+}
+
+func (m *memoryAdminClient) ListACLs(topic string) ([]ACL, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	acls := []ACL{}
+	for _, a := range m.acls {
+		if a.Topic == topic {
+			acls = append(acls, a)
+		}
+	}
+	return acls, nil
+}
+
+func (m *memoryAdminClient) CreateACL(acl ACL) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, a := range m.acls {
+		if a == acl {
+			// Creating an existing ACL is not an error in Kafka
+			return nil
+		}
+	}
+	m.acls = append(m.acls, acl)
+	return nil
+}
+
+func (m *memoryAdminClient) DeleteACL(acl ACL) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, a := range m.acls {
+		if a == acl {
+			m.acls = append(m.acls[:i], m.acls[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("ACL %v %v on %v doesn't exist", acl.Principal, acl.Operation, acl.Topic)
+}
@@ -2,6 +2,8 @@ package kafka
 
 type Features struct {
 	Enabled bool `json:"enabled"`
+	// Topics are managed by the SSP
+	Topics bool `json:"topics"`
 }
 
 func GetFeatures() Features {
@@ -9,5 +11,6 @@ func GetFeatures() Features {
 
 	return Features{
 		Enabled: kafkaConfig.BackendUrl != "",
+		Topics:  kafkaConfig.AdminClient != "",
 	}
 }
@@ -10,6 +10,16 @@ import (
 type KafkaConfig struct {
 	BackendUrl string `json:"backend_url" mapstructure:"backend_url"`
 	BillingUrl string `json:"billing_url" mapstructure:"billing_url"`
+	// Topic management, not sent to the frontend
+	AdminClient       string `json:"-" mapstructure:"admin_client"`
+	RestProxyUrl      string `json:"-" mapstructure:"rest_proxy_url"`
+	RestProxyUsername string `json:"-" mapstructure:"rest_proxy_username"`
+	RestProxyPassword string `json:"-" mapstructure:"rest_proxy_password"`
+	ClusterId         string `json:"-" mapstructure:"cluster_id"`
+	TagsFile          string `json:"-" mapstructure:"tags_file"`
+	AdminGroup        string `json:"-" mapstructure:"admin_group"`
+	MaxPartitions     int    `json:"-" mapstructure:"max_partitions"`
+	MaxRetentionHours int    `json:"-" mapstructure:"max_retention_hours"`
 }
 
 func getKafkaConfig() KafkaConfig {
@@ -25,6 +35,13 @@ func getKafkaConfig() KafkaConfig {
 
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/kafka/backend", getKafkaBackendHandler)
+	r.GET("/kafka/topics", listTopicsHandler)
+	r.POST("/kafka/topics", createTopicHandler)
+	r.DELETE("/kafka/topics/:topic", deleteTopicHandler)
+	r.PUT("/kafka/topics/:topic/tags", updateTopicTagsHandler)
+	r.GET("/kafka/topics/:topic/acls", listACLsHandler)
+	r.POST("/kafka/topics/:topic/acls", createACLHandler)
+	r.DELETE("/kafka/topics/:topic/acls", deleteACLHandler)
 }
 
 func getKafkaBackendHandler(c *gin.Context) {
@@ -0,0 +1,266 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	restProxyTimeout = 30 * time.Second
+	msPerHour        = 60 * 60 * 1000
+)
+
+// restProxyAdminClient manages the cluster with the Kafka REST Proxy API v3
+// (https://docs.confluent.io/platform/current/kafka-rest/api.html).
+// The tags of the topics are stored in kafka.tags_file.
+type restProxyAdminClient struct {
+	baseUrl   string
+	clusterId string
+	username  string
+	password  string
+	client    *http.Client
+	tags      *topicTagStore
+}
+
+type restProxyTopic struct {
+	TopicName       string `json:"topic_name"`
+	PartitionsCount int    `json:"partitions_count"`
+	IsInternal      bool   `json:"is_internal"`
+}
+
+type restProxyConfig struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type restProxyACL struct {
+	ResourceType string `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	PatternType  string `json:"pattern_type"`
+	Principal    string `json:"principal"`
+	Host         string `json:"host"`
+	Operation    string `json:"operation"`
+	Permission   string `json:"permission"`
+}
+
+func newRestProxyAdminClient(cfg KafkaConfig) (adminClient, error) {
+	if cfg.RestProxyUrl == "" {
+		return nil, errors.New("kafka.rest_proxy_url must be specified")
+	}
+	tags, err := loadTopicTagStore(cfg.TagsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &restProxyAdminClient{
+		baseUrl:   strings.TrimSuffix(cfg.RestProxyUrl, "/"),
+		clusterId: cfg.ClusterId,
+		username:  cfg.RestProxyUsername,
+		password:  cfg.RestProxyPassword,
+		client:    &http.Client{Timeout: restProxyTimeout},
+		tags:      tags,
+	}
+	if c.clusterId == "" {
+		// The REST Proxy usually serves one cluster
+		var clusters struct {
+			Data []struct {
+				ClusterId string `json:"cluster_id"`
+			} `json:"data"`
+		}
+		if err := c.call("GET", "/v3/clusters", nil, http.StatusOK, &clusters); err != nil {
+			return nil, err
+		}
+		if len(clusters.Data) != 1 {
+			return nil, fmt.Errorf("The Kafka REST Proxy has %v clusters. Set kafka.cluster_id", len(clusters.Data))
+		}
+		c.clusterId = clusters.Data[0].ClusterId
+	}
+	return c, nil
+}
+
+func (c *restProxyAdminClient) topicPath(name string) string {
+	return fmt.Sprintf("/v3/clusters/%v/topics/%v", url.PathEscape(c.clusterId), url.PathEscape(name))
+}
+
+// aclPath returns the path of the ACLs of the topic. If principal and operation are
+// empty, all ACLs of the topic match.
+func (c *restProxyAdminClient) aclPath(topic string, principal string, operation string) string {
+	query := url.Values{}
+	query.Set("resource_type", "TOPIC")
+	query.Set("resource_name", topic)
+	query.Set("pattern_type", "LITERAL")
+	if principal != "" {
+		query.Set("principal", principal)
+		query.Set("host", "*")
+		query.Set("operation", operation)
+		query.Set("permission", "ALLOW")
+	}
+	return fmt.Sprintf("/v3/clusters/%v/acls?%v", url.PathEscape(c.clusterId), query.Encode())
+}
+
+// call sends the request and decodes the response into result, if it is not nil
+func (c *restProxyAdminClient) call(method string, urlPart string, body interface{}, expectedStatus int, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseUrl+urlPart, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Kafka REST Proxy %v %v: status %v: %v", method, urlPart, resp.StatusCode, string(bodyBytes))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *restProxyAdminClient) ListTopics() ([]Topic, error) {
+	var list struct {
+		Data []restProxyTopic `json:"data"`
+	}
+	if err := c.call("GET", fmt.Sprintf("/v3/clusters/%v/topics", url.PathEscape(c.clusterId)), nil, http.StatusOK, &list); err != nil {
+		return nil, err
+	}
+
+	topics := []Topic{}
+	for _, t := range list.Data {
+		if t.IsInternal {
+			continue
+		}
+		var retention restProxyConfig
+		if err := c.call("GET", c.topicPath(t.TopicName)+"/configs/retention.ms", nil, http.StatusOK, &retention); err != nil {
+			return nil, err
+		}
+		// -1 is unlimited
+		retentionHours := -1
+		if retentionMs, _ := strconv.Atoi(retention.Value); retentionMs >= 0 {
+			retentionHours = retentionMs / msPerHour
+		}
+		tags := c.tags.get(t.TopicName)
+		topics = append(topics, Topic{
+			Name:           t.TopicName,
+			Partitions:     t.PartitionsCount,
+			RetentionHours: retentionHours,
+			Owner:          tags.Owner,
+			Billing:        tags.Billing,
+			Project:        tags.Project,
+		})
+	}
+	return topics, nil
+}
+
+func (c *restProxyAdminClient) CreateTopic(topic Topic) error {
+	body := struct {
+		TopicName       string            `json:"topic_name"`
+		PartitionsCount int               `json:"partitions_count"`
+		Configs         []restProxyConfig `json:"configs"`
+	}{
+		TopicName:       topic.Name,
+		PartitionsCount: topic.Partitions,
+		Configs: []restProxyConfig{
+			{Name: "retention.ms", Value: strconv.Itoa(topic.RetentionHours * msPerHour)},
+		},
+	}
+	if err := c.call("POST", fmt.Sprintf("/v3/clusters/%v/topics", url.PathEscape(c.clusterId)), body, http.StatusCreated, nil); err != nil {
+		return err
+	}
+
+	tags := topicTags{Owner: topic.Owner, Billing: topic.Billing, Project: topic.Project}
+	if err := c.tags.set(topic.Name, tags); err != nil {
+		// A topic without owner can only be managed by admins
+		log.Printf("Error saving the tags of kafka topic %v: %v. Deleting the topic", topic.Name, err)
+		if err := c.call("DELETE", c.topicPath(topic.Name), nil, http.StatusNoContent, nil); err != nil {
+			log.Printf("Error deleting kafka topic %v: %v", topic.Name, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *restProxyAdminClient) UpdateTopicTags(name string, billing string, project string) error {
+	tags := c.tags.get(name)
+	tags.Billing = billing
+	tags.Project = project
+	return c.tags.set(name, tags)
+}
+
+func (c *restProxyAdminClient) DeleteTopic(name string) error {
+	if err := c.call("DELETE", c.topicPath(name), nil, http.StatusNoContent, nil); err != nil {
+		return err
+	}
+	return c.tags.remove(name)
+}
+
+func (c *restProxyAdminClient) ListACLs(topic string) ([]ACL, error) {
+	var list struct {
+		Data []restProxyACL `json:"data"`
+	}
+	if err := c.call("GET", c.aclPath(topic, "", ""), nil, http.StatusOK, &list); err != nil {
+		return nil, err
+	}
+
+	acls := []ACL{}
+	for _, a := range list.Data {
+		// The SSP only creates ALLOW ACLs
+		if a.Permission != "ALLOW" {
+			continue
+		}
+		acls = append(acls, ACL{Topic: a.ResourceName, Principal: a.Principal, Operation: a.Operation})
+	}
+	return acls, nil
+}
+
+func (c *restProxyAdminClient) CreateACL(acl ACL) error {
+	body := restProxyACL{
+		ResourceType: "TOPIC",
+		ResourceName: acl.Topic,
+		PatternType:  "LITERAL",
+		Principal:    acl.Principal,
+		Host:         "*",
+		Operation:    acl.Operation,
+		Permission:   "ALLOW",
+	}
+	return c.call("POST", fmt.Sprintf("/v3/clusters/%v/acls", url.PathEscape(c.clusterId)), body, http.StatusCreated, nil)
+}
+
+func (c *restProxyAdminClient) DeleteACL(acl ACL) error {
+	var deleted struct {
+		Data []restProxyACL `json:"data"`
+	}
+	if err := c.call("DELETE", c.aclPath(acl.Topic, acl.Principal, acl.Operation), nil, http.StatusOK, &deleted); err != nil {
+		return err
+	}
+	if len(deleted.Data) == 0 {
+		return fmt.Errorf("ACL %v %v on %v doesn't exist", acl.Principal, acl.Operation, acl.Topic)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// newFakeRestProxy returns the responses by method and path and records the request bodies
+func newFakeRestProxy(t *testing.T, responses map[string]fakeResponse, requests map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.RequestURI()
+		body, _ := ioutil.ReadAll(r.Body)
+		requests[key] = string(body)
+		resp, ok := responses[key]
+		if !ok {
+			t.Logf("Unexpected request: %v", key)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+}
+
+func TestRestProxyAdminClient(t *testing.T) {
+	requests := map[string]string{}
+	proxy := newFakeRestProxy(t, map[string]fakeResponse{
+		"GET /v3/clusters": {200, `{"data": [{"cluster_id": "c1"}]}`},
+		"GET /v3/clusters/c1/topics": {200, `{"data": [
+			{"topic_name": "topic1", "partitions_count": 3, "is_internal": false},
+			{"topic_name": "__consumer_offsets", "partitions_count": 50, "is_internal": true}
+		]}`},
+		"GET /v3/clusters/c1/topics/topic1/configs/retention.ms": {200, `{"name": "retention.ms", "value": "86400000"}`},
+		"POST /v3/clusters/c1/topics":                            {201, `{}`},
+		"DELETE /v3/clusters/c1/topics/topic1":                   {204, ``},
+		"GET /v3/clusters/c1/acls?pattern_type=LITERAL&resource_name=topic1&resource_type=TOPIC": {200, `{"data": [
+			{"resource_name": "topic1", "principal": "User:svc", "operation": "READ", "permission": "ALLOW"},
+			{"resource_name": "topic1", "principal": "User:svc", "operation": "WRITE", "permission": "DENY"}
+		]}`},
+		"DELETE /v3/clusters/c1/acls?host=%2A&operation=READ&pattern_type=LITERAL&permission=ALLOW&principal=User%3Asvc&resource_name=topic1&resource_type=TOPIC":  {200, `{"data": [{}]}`},
+		"DELETE /v3/clusters/c1/acls?host=%2A&operation=WRITE&pattern_type=LITERAL&permission=ALLOW&principal=User%3Asvc&resource_name=topic1&resource_type=TOPIC": {200, `{"data": []}`},
+	}, requests)
+	defer proxy.Close()
+
+	tagsFile := filepath.Join(t.TempDir(), "tags.json")
+	cfg := KafkaConfig{RestProxyUrl: proxy.URL + "/", TagsFile: tagsFile}
+	c, err := newRestProxyAdminClient(cfg)
+	if err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+
+	if err := c.CreateTopic(Topic{Name: "topic1", Partitions: 3, RetentionHours: 24, Owner: "u123456", Billing: "1234", Project: "p"}); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	expectedBody := `{"topic_name":"topic1","partitions_count":3,"configs":[{"name":"retention.ms","value":"86400000"}]}`
+	if body := requests["POST /v3/clusters/c1/topics"]; body != expectedBody {
+		t.Errorf("ERROR! expected %v, got %v", expectedBody, body)
+	}
+
+	// The tags survive a restart
+	c, _ = newRestProxyAdminClient(cfg)
+	topics, err := c.ListTopics()
+	expected := []Topic{{Name: "topic1", Partitions: 3, RetentionHours: 24, Owner: "u123456", Billing: "1234", Project: "p"}}
+	if err != nil || !reflect.DeepEqual(topics, expected) {
+		t.Errorf("ERROR! expected %v, got %v (%v)", expected, topics, err)
+	}
+
+	acls, err := c.ListACLs("topic1")
+	if err != nil || !reflect.DeepEqual(acls, []ACL{{"topic1", "User:svc", "READ"}}) {
+		t.Errorf("ERROR! unexpected ACLs %v (%v)", acls, err)
+	}
+	if err := c.DeleteACL(ACL{"topic1", "User:svc", "READ"}); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	if err := c.DeleteACL(ACL{"topic1", "User:svc", "WRITE"}); err == nil {
+		t.Errorf("ERROR! expected error for unknown ACL")
+	}
+
+	if err := c.DeleteTopic("topic1"); err != nil {
+		t.Errorf("ERROR! unexpected error: %v", err)
+	}
+	c, _ = newRestProxyAdminClient(cfg)
+	if topics, _ := c.ListTopics(); topics[0].Owner != "" {
+		t.Errorf("ERROR! expected the tags to be deleted, got %v", topics)
+	}
+}
+
+func TestNewRestProxyAdminClient(t *testing.T) {
+	var testsets = []struct {
+		cfg       KafkaConfig
+		expectErr bool
+	}{
+		{KafkaConfig{RestProxyUrl: "http://proxy", ClusterId: "c1", TagsFile: filepath.Join(t.TempDir(), "tags.json")}, false},
+		{KafkaConfig{ClusterId: "c1", TagsFile: filepath.Join(t.TempDir(), "tags.json")}, true},
+		// The tags must be stored
+		{KafkaConfig{RestProxyUrl: "http://proxy", ClusterId: "c1"}, true},
+	}
+	for i, tt := range testsets {
+		_, err := newRestProxyAdminClient(tt.cfg)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// topicTags are the tags of a topic. Kafka can't store them with the topic.
+type topicTags struct {
+	Owner   string `json:"owner"`
+	Billing string `json:"billing"`
+	Project string `json:"project"`
+}
+
+// topicTagStore keeps the tags of all topics in a file (kafka.tags_file),
+// so that they survive a restart
+type topicTagStore struct {
+	mutex sync.Mutex
+	path  string
+	tags  map[string]topicTags
+}
+
+// loadTopicTagStore reads the tags from path. A missing file is an empty store.
+func loadTopicTagStore(path string) (*topicTagStore, error) {
+	if path == "" {
+		return nil, errors.New("kafka.tags_file must be specified")
+	}
+	s := &topicTagStore{path: path, tags: map[string]topicTags{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.tags); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *topicTagStore) get(name string) topicTags {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.tags[name]
+}
+
+// set saves the tags of the topic. If the file can't be written, the old tags are kept.
+func (s *topicTagStore) set(name string, tags topicTags) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old, ok := s.tags[name]
+	s.tags[name] = tags
+	if err := s.save(); err != nil {
+		if ok {
+			s.tags[name] = old
+		} else {
+			delete(s.tags, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *topicTagStore) remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.tags[name]; !ok {
+		return nil
+	}
+	delete(s.tags, name)
+	return s.save()
+}
+
+// save writes the store to the file. The caller must hold the lock.
+func (s *topicTagStore) save() error {
+	data, err := json.Marshal(s.tags)
+	if err != nil {
+		return err
+	}
+	// Replace the file at once, so that a crash doesn't leave a partial file
+	if err := ioutil.WriteFile(s.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
@@ -0,0 +1,420 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	wrongAPIUsageError = "Invalid api call - parameters did not match to method definition"
+	genericAPIError    = "Error when calling the Kafka API. Please open a Jira issue"
+
+	defaultMaxPartitions     = 12
+	defaultMaxRetentionHours = 7 * 24
+)
+
+var (
+	errTopicPermission = errors.New("You don't have permissions for this topic!")
+	errTopicNotFound   = errors.New("Topic not found")
+
+	// Kafka allows max. 249 characters
+	topicNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+	principalRegex = regexp.MustCompile(`^User:[a-zA-Z0-9._-]+$`)
+	aclOperations  = []string{"READ", "WRITE", "DESCRIBE"}
+
+	// Can be replaced in tests
+	getGroups = ldap.GetGroups
+)
+
+type TopicCommand struct {
+	Name           string `json:"name"`
+	Partitions     int    `json:"partitions"`
+	RetentionHours int    `json:"retentionHours"`
+	Billing        string `json:"billing"`
+	Project        string `json:"project"`
+}
+
+type TopicTagsCommand struct {
+	Billing string `json:"billing"`
+	Project string `json:"project"`
+}
+
+type ACLCommand struct {
+	Principal string `json:"principal"`
+	Operation string `json:"operation"`
+}
+
+func listTopicsHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	topics, err := listTopics(client, username)
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, topics)
+}
+
+func createTopicHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	var data TopicCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if err := createTopic(client, username, data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{
+		Message: fmt.Sprintf("Topic %v has been created.", data.Name),
+	})
+}
+
+func deleteTopicHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	name := c.Param("topic")
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if err := deleteTopic(client, username, name); err != nil {
+		c.JSON(getErrorStatus(err), common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{
+		Message: fmt.Sprintf("Topic %v has been deleted.", name),
+	})
+}
+
+func updateTopicTagsHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	name := c.Param("topic")
+
+	var data TopicTagsCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if err := updateTopicTags(client, username, name, data); err != nil {
+		c.JSON(getErrorStatus(err), common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{
+		Message: fmt.Sprintf("Accounting number (%v / %v) has been saved.", data.Billing, data.Project),
+	})
+}
+
+func listACLsHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	name := c.Param("topic")
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if _, err := getTopicForUser(client, username, name); err != nil {
+		c.JSON(getErrorStatus(err), common.ApiResponse{Message: err.Error()})
+		return
+	}
+	acls, err := client.ListACLs(name)
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, acls)
+}
+
+func createACLHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	var data ACLCommand
+	if c.BindJSON(&data) != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	acl := ACL{Topic: c.Param("topic"), Principal: data.Principal, Operation: data.Operation}
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if err := createACL(client, username, acl); err != nil {
+		c.JSON(getErrorStatus(err), common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{
+		Message: fmt.Sprintf("%v has been allowed to %v topic %v.", acl.Principal, acl.Operation, acl.Topic),
+	})
+}
+
+func deleteACLHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	acl := ACL{Topic: c.Param("topic"), Principal: c.Query("principal"), Operation: c.Query("operation")}
+
+	client, err := getAdminClient()
+	if err != nil {
+		log.Println(err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	if err := deleteACL(client, username, acl); err != nil {
+		c.JSON(getErrorStatus(err), common.ApiResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{
+		Message: fmt.Sprintf("The ACL of %v has been deleted.", acl.Principal),
+	})
+}
+
+func getErrorStatus(err error) int {
+	switch err {
+	case errTopicPermission:
+		return http.StatusForbidden
+	case errTopicNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// listTopics returns the topics of the user. Admins get all topics.
+func listTopics(client adminClient, username string) ([]Topic, error) {
+	topics, err := client.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+	if isKafkaAdmin(username) {
+		return topics, nil
+	}
+	userTopics := []Topic{}
+	for _, t := range topics {
+		if t.Owner == username {
+			userTopics = append(userTopics, t)
+		}
+	}
+	return userTopics, nil
+}
+
+func createTopic(client adminClient, username string, data TopicCommand) error {
+	if err := validateNewTopic(data); err != nil {
+		return err
+	}
+
+	topic := Topic{
+		Name:           data.Name,
+		Partitions:     data.Partitions,
+		RetentionHours: data.RetentionHours,
+		Owner:          username,
+		Billing:        data.Billing,
+		Project:        data.Project,
+	}
+	log.Printf("User %v creates kafka topic %+v", username, topic)
+	if err := client.CreateTopic(topic); err != nil {
+		log.Println("Error creating kafka topic: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	return nil
+}
+
+func validateNewTopic(data TopicCommand) error {
+	kafkaConfig := getKafkaConfig()
+	maxPartitions := kafkaConfig.MaxPartitions
+	if maxPartitions == 0 {
+		maxPartitions = defaultMaxPartitions
+	}
+	maxRetentionHours := kafkaConfig.MaxRetentionHours
+	if maxRetentionHours == 0 {
+		maxRetentionHours = defaultMaxRetentionHours
+	}
+
+	if !topicNameRegex.MatchString(data.Name) {
+		return errors.New("Topic name must only contain letters, digits, '.', '_' and '-'")
+	}
+
+	if data.Partitions < 1 || data.Partitions > maxPartitions {
+		return fmt.Errorf("Partitions must be between 1 and %v", maxPartitions)
+	}
+
+	if data.RetentionHours < 1 || data.RetentionHours > maxRetentionHours {
+		return fmt.Errorf("Retention must be between 1 and %v hours", maxRetentionHours)
+	}
+
+	return validateTopicTags(data.Billing, data.Project)
+}
+
+func validateTopicTags(billing string, project string) error {
+	if len(billing) == 0 {
+		return errors.New("Accounting number must be provided!")
+	}
+
+	if len(project) == 0 {
+		return errors.New("Project name must be provided!")
+	}
+
+	return nil
+}
+
+func deleteTopic(client adminClient, username string, name string) error {
+	if _, err := getTopicForUser(client, username, name); err != nil {
+		return err
+	}
+
+	log.Printf("User %v deletes kafka topic %v", username, name)
+	// Kafka keeps the ACLs of a deleted topic, they would apply to a new topic with the same name.
+	// They are deleted first, so that the deletion can be retried if it fails.
+	acls, err := client.ListACLs(name)
+	if err != nil {
+		log.Println("Error listing kafka ACLs: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	for _, acl := range acls {
+		if err := client.DeleteACL(acl); err != nil {
+			log.Println("Error deleting kafka ACL: ", err.Error())
+			return errors.New(genericAPIError)
+		}
+	}
+	if err := client.DeleteTopic(name); err != nil {
+		log.Println("Error deleting kafka topic: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	return nil
+}
+
+func updateTopicTags(client adminClient, username string, name string, data TopicTagsCommand) error {
+	if err := validateTopicTags(data.Billing, data.Project); err != nil {
+		return err
+	}
+	if _, err := getTopicForUser(client, username, name); err != nil {
+		return err
+	}
+
+	log.Printf("User %v updates kafka topic %v to %v / %v", username, name, data.Billing, data.Project)
+	if err := client.UpdateTopicTags(name, data.Billing, data.Project); err != nil {
+		log.Println("Error updating kafka topic tags: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	return nil
+}
+
+func createACL(client adminClient, username string, acl ACL) error {
+	if err := validateACL(acl); err != nil {
+		return err
+	}
+	if _, err := getTopicForUser(client, username, acl.Topic); err != nil {
+		return err
+	}
+
+	log.Printf("User %v creates kafka ACL %+v", username, acl)
+	if err := client.CreateACL(acl); err != nil {
+		log.Println("Error creating kafka ACL: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	return nil
+}
+
+func deleteACL(client adminClient, username string, acl ACL) error {
+	if err := validateACL(acl); err != nil {
+		return err
+	}
+	if _, err := getTopicForUser(client, username, acl.Topic); err != nil {
+		return err
+	}
+
+	log.Printf("User %v deletes kafka ACL %+v", username, acl)
+	if err := client.DeleteACL(acl); err != nil {
+		log.Println("Error deleting kafka ACL: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+	return nil
+}
+
+func validateACL(acl ACL) error {
+	if !principalRegex.MatchString(acl.Principal) {
+		return errors.New("Principal must be a service principal like User:<name>")
+	}
+
+	for _, o := range aclOperations {
+		if acl.Operation == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("Operation must be one of: %v", aclOperations)
+}
+
+// getTopicForUser returns the topic, if the user is the owner or an admin
+func getTopicForUser(client adminClient, username string, name string) (Topic, error) {
+	topics, err := client.ListTopics()
+	if err != nil {
+		log.Println("Error listing kafka topics: ", err.Error())
+		return Topic{}, errors.New(genericAPIError)
+	}
+	for _, t := range topics {
+		if t.Name != name {
+			continue
+		}
+		if t.Owner != username && !isKafkaAdmin(username) {
+			log.Printf("User %v tried to access kafka topic %v of %v", username, name, t.Owner)
+			return Topic{}, errTopicPermission
+		}
+		return t, nil
+	}
+	return Topic{}, errTopicNotFound
+}
+
+// isKafkaAdmin returns true if the admin group is configured and the user is a member
+func isKafkaAdmin(username string) bool {
+	adminGroup := getKafkaConfig().AdminGroup
+	if adminGroup == "" {
+		return false
+	}
+	groups, err := getGroups(username)
+	if err != nil {
+		log.Println("Error getting ldap groups of " + username + ": " + err.Error())
+		return false
+	}
+	return common.ContainsStringI(groups, adminGroup)
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+)
+
+func newTestClient(t *testing.T) adminClient {
+	config.Init("bla")
+	config.Config().Set("kafka.admin_group", "KAFKA_ADMINS")
+	config.Config().Set("kafka.max_partitions", 6)
+
+	getGroups = func(username string) ([]string, error) {
+		if username == "admin" {
+			return []string{"kafka_admins"}, nil
+		}
+		return []string{}, nil
+	}
+	c, _ := newMemoryAdminClient(KafkaConfig{})
+	c.CreateTopic(Topic{Name: "topic1", Owner: "u123456", Partitions: 1, RetentionHours: 24, Billing: "1234", Project: "p"})
+	c.CreateTopic(Topic{Name: "topic2", Owner: "u654321", Partitions: 1, RetentionHours: 24, Billing: "1234", Project: "p"})
+	return c
+}
+
+func TestValidateNewTopic(t *testing.T) {
+	newTestClient(t)
+	defer func() { getGroups = ldap.GetGroups }()
+
+	var testsets = []struct {
+		data      TopicCommand
+		expectErr bool
+	}{
+		{TopicCommand{"topic", 6, 168, "1234", "project"}, false},
+		{TopicCommand{"my.topic_1-a", 1, 1, "1234", "project"}, false},
+		{TopicCommand{"", 1, 1, "1234", "project"}, true},
+		{TopicCommand{"topic/1", 1, 1, "1234", "project"}, true},
+		{TopicCommand{"topic", 0, 1, "1234", "project"}, true},
+		{TopicCommand{"topic", 7, 1, "1234", "project"}, true},
+		{TopicCommand{"topic", 1, 0, "1234", "project"}, true},
+		{TopicCommand{"topic", 1, 169, "1234", "project"}, true},
+		{TopicCommand{"topic", 1, 1, "", "project"}, true},
+		{TopicCommand{"topic", 1, 1, "1234", ""}, true},
+	}
+	for _, tt := range testsets {
+		err := validateNewTopic(tt.data)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %+v: expected error: %v, got: %v", tt.data, tt.expectErr, err)
+		}
+	}
+}
+
+func TestListTopics(t *testing.T) {
+	c := newTestClient(t)
+	defer func() { getGroups = ldap.GetGroups }()
+
+	var testsets = []struct {
+		username      string
+		expectedCount int
+	}{
+		{"u123456", 1},
+		{"admin", 2},
+		{"u000000", 0},
+	}
+	for _, tt := range testsets {
+		topics, err := listTopics(c, tt.username)
+		if err != nil || len(topics) != tt.expectedCount {
+			t.Errorf("ERROR! %v: expected %v topics, got %v (%v)", tt.username, tt.expectedCount, topics, err)
+		}
+	}
+}
+
+func TestCreateTopic(t *testing.T) {
+	c := newTestClient(t)
+	defer func() { getGroups = ldap.GetGroups }()
+
+	if err := createTopic(c, "u123456", TopicCommand{"topic3", 3, 24, "1234", "project"}); err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	topic, err := getTopicForUser(c, "u123456", "topic3")
+	if err != nil || topic.Owner != "u123456" || topic.Partitions != 3 || topic.Billing != "1234" {
+		t.Errorf("ERROR! unexpected topic %+v (%v)", topic, err)
+	}
+	if err := createTopic(c, "u123456", TopicCommand{"topic3", 3, 24, "1234", "project"}); err == nil {
+		t.Errorf("ERROR! expected error for existing topic")
+	}
+}
+
+func TestTopicPermissions(t *testing.T) {
+	c := newTestClient(t)
+	defer func() { getGroups = ldap.GetGroups }()
+
+	acl := ACL{Topic: "topic1", Principal: "User:svc-app", Operation: "WRITE"}
+	var testsets = []struct {
+		name        string
+		run         func() error
+		expectedErr error
+	}{
+		{"other user tags", func() error {
+			return updateTopicTags(c, "u654321", "topic1", TopicTagsCommand{"5678", "other"})
+		}, errTopicPermission},
+		{"owner tags", func() error {
+			return updateTopicTags(c, "u123456", "topic1", TopicTagsCommand{"5678", "other"})
+		}, nil},
+		{"other user ACL", func() error { return createACL(c, "u654321", acl) }, errTopicPermission},
+		{"owner ACL", func() error { return createACL(c, "u123456", acl) }, nil},
+		{"admin ACL", func() error { return deleteACL(c, "admin", acl) }, nil},
+		{"unknown topic", func() error { return deleteTopic(c, "u123456", "topic9") }, errTopicNotFound},
+		{"other user delete", func() error { return deleteTopic(c, "u123456", "topic2") }, errTopicPermission},
+		{"owner delete", func() error { return deleteTopic(c, "u123456", "topic1") }, nil},
+	}
+	for _, tt := range testsets {
+		if err := tt.run(); err != tt.expectedErr {
+			t.Errorf("ERROR! %v: expected error: %v, got: %v", tt.name, tt.expectedErr, err)
+		}
+	}
+}
+
+func TestValidateACL(t *testing.T) {
+	var testsets = []struct {
+		acl       ACL
+		expectErr bool
+	}{
+		{ACL{"topic", "User:svc-app", "READ"}, false},
+		{ACL{"topic", "User:svc.app_1", "DESCRIBE"}, false},
+		{ACL{"topic", "User:*", "READ"}, true},
+		{ACL{"topic", "Group:devs", "READ"}, true},
+		{ACL{"topic", "User:svc-app", "ALL"}, true},
+		{ACL{"topic", "User:svc-app", ""}, true},
+	}
+	for _, tt := range testsets {
+		err := validateACL(tt.acl)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %+v: expected error: %v, got: %v", tt.acl, tt.expectErr, err)
+		}
+	}
+}
+
+func TestDeleteTopicDeletesACLs(t *testing.T) {
+	c := newTestClient(t)
+	defer func() { getGroups = ldap.GetGroups }()
+
+	c.CreateACL(ACL{Topic: "topic1", Principal: "User:svc-app", Operation: "READ"})
+	c.CreateACL(ACL{Topic: "topic2", Principal: "User:svc-app", Operation: "READ"})
+	if err := deleteTopic(c, "u123456", "topic1"); err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	if acls, _ := c.ListACLs("topic1"); len(acls) != 0 {
+		t.Errorf("ERROR! expected the ACLs of the topic to be deleted, got %v", acls)
+	}
+	if acls, _ := c.ListACLs("topic2"); len(acls) != 1 {
+		t.Errorf("ERROR! expected the ACLs of other topics to be kept, got %v", acls)
+	}
+}
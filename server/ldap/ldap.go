@@ -157,6 +157,16 @@ func getCN(dn string) string {
 	return parsedDN.RDNs[0].Attributes[0].Value
 }
 
+// GetGroups returns the groups of the user with a new connection
+func GetGroups(username string) ([]string, error) {
+	l, err := New()
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	return l.GetGroupsOfUser(username)
+}
+
 func (lc *LDAPClient) GetGroupsOfUser(username string) ([]string, error) {
 	var groups []string
 	user, err := lc.GetUser(username)
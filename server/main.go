@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/aws"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/kafka"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/keycloak"
@@ -36,6 +37,7 @@ func main() {
 	corsConfig.AllowAllOrigins = true
 	corsConfig.AddAllowHeaders("authorization", "*")
 	corsConfig.AddAllowMethods("DELETE")
+	corsConfig.AddExposeHeaders(common.WarningHeader)
 	router.Use(cors.New(corsConfig))
 
 	// Public routes
@@ -67,6 +69,13 @@ func main() {
 		ldap.RegisterRoutes(auth)
 	}
 
+	// Background jobs
+	aws.StartEC2Scheduler()
+	aws.StartSnapshotRetention()
+	otc.StartScheduledECSDeletion()
+	otc.StartServerCache()
+	otc.StartRDSTagging()
+
 	log.Println("Cloud SSP is running")
 
 	port := config.Config().GetString("port")
@@ -81,6 +81,12 @@ func getProjectAdminsAndOperators(clusterId, project string) ([]string, []string
 	return common.RemoveDuplicates(admins), operators, nil
 }
 
+// CheckAdminPermissions checks if the user is an admin or operator of the project.
+// It is used by other packages, e.g. to validate Ansible Tower jobs.
+func CheckAdminPermissions(clusterId, username, project string) error {
+	return checkAdminPermissions(clusterId, username, project)
+}
+
 func checkAdminPermissions(clusterId, username, project string) error {
 	// Check if user has admin-access
 	hasAccess := false
@@ -1,7 +1,11 @@
 package otc
 
 import (
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/backups"
 )
 
 type NewECSCommand struct {
@@ -18,6 +22,7 @@ type NewECSCommand struct {
 	DataVolumeTypeId   string `json:"dataVolumeTypeId"`
 	DataDiskSize       int    `json:"dataDiskSize"`
 	MegaId             string `json:"megaId"`
+	UOSGroup           string `json:"uosGroup"`
 }
 
 type DataDisk struct {
@@ -63,3 +68,108 @@ type VolumeType struct {
 	Name string `json:"name"`
 	Id   string `json:"id"`
 }
+
+type NewVolumeCommand struct {
+	Name             string `json:"name"`
+	Stage            string `json:"stage"`
+	AvailabilityZone string `json:"availabilityZone"`
+	VolumeTypeId     string `json:"volumeTypeId"`
+	DiskSize         int    `json:"diskSize"`
+	UOSGroup         string `json:"uosGroup"`
+}
+
+type ExtendVolumeCommand struct {
+	DiskSize int `json:"diskSize"`
+}
+
+type VolumeListResponse struct {
+	Volumes []volumes.Volume `json:"volumes"`
+}
+
+type CacheStatusResponse struct {
+	Tenants []TenantCacheStatus `json:"tenants"`
+}
+
+type TenantCacheStatus struct {
+	Tenant         string    `json:"tenant"`
+	Servers        int       `json:"servers"`
+	LastRefresh    time.Time `json:"lastRefresh"`
+	LastFullResync time.Time `json:"lastFullResync"`
+	AgeSeconds     int       `json:"ageSeconds"`
+	Stale          bool      `json:"stale"`
+	RefreshErrors  int       `json:"refreshErrors"`
+	LastError      string    `json:"lastError"`
+	LastErrorTime  time.Time `json:"lastErrorTime"`
+}
+
+type NewRDSInstanceCommand struct {
+	Name             string `json:"name"`
+	Stage            string `json:"stage"`
+	Engine           string `json:"engine"`
+	Version          string `json:"version"`
+	Flavor           string `json:"flavor"`
+	HA               bool   `json:"ha"`
+	VolumeType       string `json:"volumeType"`
+	VolumeSize       int    `json:"volumeSize"`
+	AvailabilityZone string `json:"availabilityZone"`
+	Password         string `json:"password"`
+	RDSGroup         string `json:"rdsGroup"`
+	Billing          string `json:"billing"`
+}
+
+type RDSFlavorCommand struct {
+	Flavor string `json:"flavor"`
+}
+
+type RDSVolumeCommand struct {
+	VolumeSize int `json:"volumeSize"`
+}
+
+type RDSBackupCommand struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RDSBackupPolicyCommand struct {
+	KeepDays int `json:"keepDays"`
+	// UTC, e.g. 01:00-02:00
+	StartTime string `json:"startTime"`
+	// Days of the week, e.g. 1,2,3,4,5,6,7
+	Period string `json:"period"`
+}
+
+type RDSRestoreCommand struct {
+	Name string `json:"name"`
+	// Either a backup or a point in time (unix timestamp in milliseconds)
+	BackupID    string `json:"backupId"`
+	RestoreTime int64  `json:"restoreTime"`
+	Flavor      string `json:"flavor"`
+	Password    string `json:"password"`
+}
+
+type RDSBackupListResponse struct {
+	Backups      []backups.BackupsResp     `json:"backups"`
+	Policy       backups.ListBackupsPolicy `json:"policy"`
+	RestoreTimes []backups.RestoreTime     `json:"restoreTimes"`
+}
+
+type ECSActionCommand struct {
+	Servers []servers.Server `json:"servers"`
+	// Reboot type: soft (default) or hard
+	Type string `json:"type"`
+	// Wait until the servers have the target state
+	Wait bool `json:"wait"`
+}
+
+type ECSActionResult struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// accepted or failed
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type ECSActionResponse struct {
+	Message string            `json:"message"`
+	Results []ECSActionResult `json:"results"`
+}
@@ -0,0 +1,249 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Used if otc.cache.* is not set
+	defaultCacheRefreshInterval = time.Minute
+	defaultCacheResyncInterval  = time.Hour
+	defaultCacheMaxAge          = 5 * time.Minute
+)
+
+// serverCache holds the servers of all tenants.
+// The servers are refreshed incrementally (changes-since) and
+// completely (full resync), so that deleted servers disappear.
+type serverCache struct {
+	sync.RWMutex
+	tenants map[string]*tenantCache
+}
+
+type tenantCache struct {
+	// Serializes the refreshes of the tenant
+	refreshLock sync.Mutex
+
+	servers map[string]servers.Server
+	// Start of the last successful refresh, used for changes-since
+	lastRefresh    time.Time
+	lastFullResync time.Time
+	lastError      string
+	lastErrorTime  time.Time
+	refreshErrors  int
+	// Forces a refresh on the next read, e.g. after creating a server
+	stale bool
+}
+
+var otcCache = serverCache{
+	tenants: make(map[string]*tenantCache),
+}
+
+func getCacheDuration(key string, defaultValue time.Duration) time.Duration {
+	d := config.Config().GetDuration(key)
+	if d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+func (c *serverCache) getTenant(domain string) *tenantCache {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.tenants[domain]
+	if !ok {
+		t = &tenantCache{servers: make(map[string]servers.Server)}
+		c.tenants[domain] = t
+	}
+	return t
+}
+
+// getServers returns the cached servers of the tenant.
+// If the cache is empty or older than otc.cache.max_age, it is refreshed first.
+func (c *serverCache) getServers(domain string, client *gophercloud.ServiceClient) ([]servers.Server, error) {
+	t := c.getTenant(domain)
+
+	c.RLock()
+	age := time.Since(t.lastRefresh)
+	needsRefresh := t.lastRefresh.IsZero() || t.stale || age > getCacheDuration("otc.cache.max_age", defaultCacheMaxAge)
+	c.RUnlock()
+
+	if needsRefresh {
+		if err := c.refresh(domain, client, false); err != nil {
+			return nil, err
+		}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	result := make([]servers.Server, 0, len(t.servers))
+	for _, s := range t.servers {
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// markStale forces a refresh of the tenant on the next read
+func (c *serverCache) markStale(domain string) {
+	t := c.getTenant(domain)
+	c.Lock()
+	defer c.Unlock()
+	t.stale = true
+}
+
+// refresh updates the servers of the tenant. If full is false, only the servers
+// changed since the last refresh are fetched. Deleted servers are removed.
+func (c *serverCache) refresh(domain string, client *gophercloud.ServiceClient, full bool) error {
+	t := c.getTenant(domain)
+	t.refreshLock.Lock()
+	defer t.refreshLock.Unlock()
+
+	c.RLock()
+	lastRefresh := t.lastRefresh
+	c.RUnlock()
+
+	opts := servers.ListOpts{}
+	if !full && !lastRefresh.IsZero() {
+		opts.ChangesSince = lastRefresh.UTC().Format(time.RFC3339)
+	} else {
+		full = true
+	}
+
+	start := time.Now()
+	newServers, err := listServers(client, opts)
+
+	c.Lock()
+	defer c.Unlock()
+	if err != nil {
+		t.refreshErrors++
+		t.lastError = err.Error()
+		t.lastErrorTime = time.Now()
+		log.WithFields(log.Fields{
+			"tenant": domain,
+			"full":   full,
+			"err":    err.Error(),
+		}).Error("Error while refreshing server cache")
+		return fmt.Errorf(genericOTCAPIError)
+	}
+
+	t.apply(newServers, full, start)
+	log.WithFields(log.Fields{
+		"tenant":  domain,
+		"full":    full,
+		"changed": len(newServers),
+		"servers": len(t.servers),
+	}).Debug("Refreshed server cache")
+	return nil
+}
+
+// apply merges the refreshed servers into the cache. The cache must be locked.
+func (t *tenantCache) apply(newServers []servers.Server, full bool, start time.Time) {
+	if full {
+		t.servers = make(map[string]servers.Server)
+		t.lastFullResync = start
+	}
+	for _, s := range newServers {
+		// changes-since also returns deleted servers
+		if s.Status == "DELETED" {
+			delete(t.servers, s.ID)
+			continue
+		}
+		t.servers[s.ID] = s
+	}
+	t.lastRefresh = start
+	t.stale = false
+}
+
+func listServers(client *gophercloud.ServiceClient, opts servers.ListOpts) ([]servers.Server, error) {
+	allPages, err := servers.List(client, opts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	return servers.ExtractServers(allPages)
+}
+
+// StartServerCache refreshes the server cache of every tenant in the background.
+// It runs until the server stops.
+func StartServerCache() {
+	if !GetFeatures().UOS {
+		return
+	}
+	domains, err := getTenantDomains()
+	if err != nil {
+		log.Printf("Server cache: error getting tenants: %v", err)
+		return
+	}
+	refreshInterval := getCacheDuration("otc.cache.refresh_interval", defaultCacheRefreshInterval)
+	resyncInterval := getCacheDuration("otc.cache.resync_interval", defaultCacheResyncInterval)
+	log.Printf("Starting server cache (refresh: %v, full resync: %v)", refreshInterval, resyncInterval)
+
+	for _, domain := range domains {
+		go func(domain string) {
+			for range time.Tick(refreshInterval) {
+				client, err := getComputeClient(domain)
+				if err != nil {
+					log.Printf("Server cache: error getting compute client for %v: %v", domain, err)
+					continue
+				}
+				t := otcCache.getTenant(domain)
+				otcCache.RLock()
+				full := time.Since(t.lastFullResync) > resyncInterval
+				otcCache.RUnlock()
+				// errors are logged and shown in the status
+				otcCache.refresh(domain, client, full)
+			}
+		}(domain)
+	}
+}
+
+func getCacheStatusHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	groups, err := getGroups(username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if !common.ContainsStringI(groups, "DG_RBT_UOS_ADMINS") {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: "Only admins can see the cache status"})
+		return
+	}
+	c.JSON(http.StatusOK, otcCache.status(time.Now()))
+}
+
+func (c *serverCache) status(now time.Time) CacheStatusResponse {
+	maxAge := getCacheDuration("otc.cache.max_age", defaultCacheMaxAge)
+
+	c.RLock()
+	defer c.RUnlock()
+	response := CacheStatusResponse{Tenants: []TenantCacheStatus{}}
+	for domain, t := range c.tenants {
+		status := TenantCacheStatus{
+			Tenant:         domain,
+			Servers:        len(t.servers),
+			LastRefresh:    t.lastRefresh,
+			LastFullResync: t.lastFullResync,
+			RefreshErrors:  t.refreshErrors,
+			LastError:      t.lastError,
+			LastErrorTime:  t.lastErrorTime,
+			Stale:          t.stale || t.lastRefresh.IsZero() || now.Sub(t.lastRefresh) > maxAge,
+		}
+		if !t.lastRefresh.IsZero() {
+			status.AgeSeconds = int(now.Sub(t.lastRefresh).Seconds())
+		}
+		response.Tenants = append(response.Tenants, status)
+	}
+	sort.Slice(response.Tenants, func(i, j int) bool {
+		return response.Tenants[i].Tenant < response.Tenants[j].Tenant
+	})
+	return response
+}
@@ -0,0 +1,70 @@
+package otc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+)
+
+func TestTenantCacheApply(t *testing.T) {
+	start := time.Date(2020, 5, 10, 12, 0, 0, 0, time.UTC)
+	cache := tenantCache{servers: make(map[string]servers.Server), stale: true}
+
+	cache.apply([]servers.Server{{ID: "1"}, {ID: "2"}, {ID: "3"}}, true, start)
+	if len(cache.servers) != 3 || cache.stale || !cache.lastFullResync.Equal(start) {
+		t.Errorf("ERROR! full resync: expected 3 servers, got %v", cache.servers)
+	}
+
+	// incremental refresh: changed and deleted servers
+	cache.apply([]servers.Server{{ID: "2", Status: "SHUTOFF"}, {ID: "3", Status: "DELETED"}, {ID: "4"}}, false, start.Add(time.Minute))
+	if len(cache.servers) != 3 || cache.servers["2"].Status != "SHUTOFF" {
+		t.Errorf("ERROR! incremental refresh: unexpected servers %v", cache.servers)
+	}
+	if _, ok := cache.servers["3"]; ok {
+		t.Errorf("ERROR! deleted server is still in the cache")
+	}
+	if !cache.lastFullResync.Equal(start) || !cache.lastRefresh.Equal(start.Add(time.Minute)) {
+		t.Errorf("ERROR! incremental refresh: wrong timestamps %v %v", cache.lastFullResync, cache.lastRefresh)
+	}
+
+	// full resync removes servers, which were deleted without notice
+	cache.apply([]servers.Server{{ID: "1"}}, true, start.Add(time.Hour))
+	if len(cache.servers) != 1 {
+		t.Errorf("ERROR! full resync: expected 1 server, got %v", cache.servers)
+	}
+}
+
+func TestServerCacheStatus(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("otc.cache.max_age", "5m")
+	now := time.Date(2020, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	cache := serverCache{tenants: map[string]*tenantCache{
+		"SBB_RZ_T_001": {servers: map[string]servers.Server{"1": {ID: "1"}}, lastRefresh: now.Add(-time.Minute)},
+		"SBB_RZ_P_001": {servers: map[string]servers.Server{}, lastRefresh: now.Add(-10 * time.Minute), refreshErrors: 2},
+		"SBB_RZ_X_001": {servers: map[string]servers.Server{}},
+	}}
+
+	status := cache.status(now)
+	if len(status.Tenants) != 3 {
+		t.Fatalf("ERROR! expected 3 tenants, got %v", len(status.Tenants))
+	}
+	var testsets = []struct {
+		tenant     string
+		servers    int
+		ageSeconds int
+		stale      bool
+	}{
+		{"SBB_RZ_P_001", 0, 600, true},
+		{"SBB_RZ_T_001", 1, 60, false},
+		{"SBB_RZ_X_001", 0, 0, true},
+	}
+	for i, tt := range testsets {
+		s := status.Tenants[i]
+		if s.Tenant != tt.tenant || s.Servers != tt.servers || s.AgeSeconds != tt.ageSeconds || s.Stale != tt.stale {
+			t.Errorf("ERROR! expected %+v, got %+v", tt, s)
+		}
+	}
+}
@@ -3,22 +3,17 @@ package otc
 import (
 	"fmt"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
-	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
 	"github.com/gin-gonic/gin"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v1/volumetypes"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
-	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	log "github.com/sirupsen/logrus"
 	"net/http"
-	"regexp"
 	"strconv"
-	"strings"
-	"time"
 )
 
 func listECSHandler(c *gin.Context) {
@@ -56,17 +51,12 @@ func listECSHandler(c *gin.Context) {
 
 func listFlavorsHandler(c *gin.Context) {
 	log.Println("Querying flavors @ OTC.")
-	stage := c.Request.URL.Query().Get("stage")
-	if stage == "" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Wrong API usage. Missing parameter stage"})
-		return
-	}
-	if stage != "p" && stage != "t" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Wrong API usage. Parameter stage is: %v. Should be p or t", stage)})
+	t, err := getTenantByStage(c.Request.URL.Query().Get("stage"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	tenant := fmt.Sprintf("SBB_RZ_%v_001", strings.ToUpper(stage))
-	client, err := getComputeClient(tenant)
+	client, err := getComputeClient(t.Domain)
 
 	if err != nil {
 		fmt.Println("Error getting compute client.", err.Error())
@@ -87,41 +77,22 @@ func listFlavorsHandler(c *gin.Context) {
 }
 
 func listImagesHandler(c *gin.Context) {
-	type labelValue struct {
-		Label string `json:"label"`
-		Value string `json:"value"`
-	}
-	images := []labelValue{}
-	err := config.Config().UnmarshalKey("uos.images", &images)
+	images, err := getConfiguredImages()
 	if err != nil {
-		log.Printf("Error getting images: %v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: common.ConfigNotSetError})
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	if len(images) == 0 {
-		log.Printf("Error: no images found in config (uos.images)")
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: common.ConfigNotSetError})
-		return
-	}
-	for _, i := range images {
-		if i.Label == "" || i.Value == "" {
-			log.Printf("Error: missing label or value in image: %+v", i)
-			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: common.ConfigNotSetError})
-			return
-		}
-	}
 
 	c.JSON(http.StatusOK, images)
 	return
 }
 
 func getComputeClients() (map[string]*gophercloud.ServiceClient, error) {
-	tenants := []string{
-		"SBB_RZ_T_001",
-		"SBB_RZ_P_001",
+	tenants, err := getTenantDomains()
+	if err != nil {
+		return nil, err
 	}
 	clients := make(map[string]*gophercloud.ServiceClient)
-	var err error
 	for _, tenant := range tenants {
 		clients[tenant], err = getComputeClient(tenant)
 		if err != nil {
@@ -131,133 +102,6 @@ func getComputeClients() (map[string]*gophercloud.ServiceClient, error) {
 	return clients, nil
 }
 
-func getTenantName(servername string) string {
-	pattern := regexp.MustCompile(`(.)\d{2}\.sbb\.ch`)
-	matches := pattern.FindStringSubmatch(servername)
-	if len(matches) == 2 {
-		stage := matches[1]
-		return fmt.Sprintf("SBB_RZ_%v_001", strings.ToUpper(stage))
-	}
-	return ""
-}
-
-func stopECSHandler(c *gin.Context) {
-	log.Println("Stopping ECS @ OTC.")
-	username := common.GetUserName(c)
-
-	clients, err := getComputeClients()
-	if err != nil {
-		log.Printf("Error getting compute client: %v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
-		return
-	}
-
-	var data ECServerListResponse
-	err = c.BindJSON(&data)
-	if err != nil {
-		log.Println("Binding request to Go struct failed.", err.Error())
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
-		return
-	}
-	if err := validatePermissions(data.Servers, username); err != nil {
-		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
-		return
-	}
-
-	for _, server := range data.Servers {
-		tenant := getTenantName(server.Name)
-		stopResult := startstop.Stop(clients[tenant], server.ID)
-
-		if stopResult.Err != nil {
-			log.Println("Error while stopping server.", err.Error())
-			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "At least one server couldn't be stopped."})
-			return
-		}
-	}
-
-	c.JSON(http.StatusOK, common.ApiResponse{Message: "Server stop initiated."})
-	return
-}
-
-func startECSHandler(c *gin.Context) {
-	log.Println("Starting ECS @ OTC.")
-	username := common.GetUserName(c)
-
-	clients, err := getComputeClients()
-	if err != nil {
-		log.Printf("Error getting compute clients: %v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
-		return
-	}
-
-	var data ECServerListResponse
-	err = c.BindJSON(&data)
-	if err != nil {
-		log.Println("Binding request to Go struct failed.", err.Error())
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
-		return
-	}
-	if err := validatePermissions(data.Servers, username); err != nil {
-		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
-		return
-	}
-	for _, server := range data.Servers {
-		tenant := getTenantName(server.Name)
-		stopResult := startstop.Start(clients[tenant], server.ID)
-
-		if stopResult.Err != nil {
-			log.Println("Error while starting server.", err.Error())
-			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "At least one server couldn't be started."})
-			return
-		}
-	}
-
-	c.JSON(http.StatusOK, common.ApiResponse{Message: "Server start initiated."})
-	return
-}
-
-func rebootECSHandler(c *gin.Context) {
-	log.Println("Rebooting ECS @ OTC.")
-	username := common.GetUserName(c)
-
-	clients, err := getComputeClients()
-	if err != nil {
-		log.Printf("Error getting compute client: %v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
-		return
-	}
-
-	var data ECServerListResponse
-	err = c.BindJSON(&data)
-
-	if err != nil {
-		log.Println("Binding request to Go struct failed.", err.Error())
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
-		return
-	}
-
-	rebootOpts := servers.RebootOpts{
-		Type: servers.SoftReboot,
-	}
-
-	if err := validatePermissions(data.Servers, username); err != nil {
-		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
-		return
-	}
-	for _, server := range data.Servers {
-		tenant := getTenantName(server.Name)
-		rebootResult := servers.Reboot(clients[tenant], server.ID, &rebootOpts)
-
-		if rebootResult.Err != nil {
-			log.Printf("Error while rebooting server: %v", rebootResult.Err)
-			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "At least one server couldn't be rebooted."})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, common.ApiResponse{Message: "Reboot initiated."})
-	return
-}
-
 func ValidatePermissionsByHostname(servername string, username string) error {
 	if servername == "" || username == "" {
 		log.WithFields(log.Fields{
@@ -375,22 +219,18 @@ func createKeyPair(client *gophercloud.ServiceClient, publicKeyName string, publ
 	return keyPair, nil
 }
 
-type otcTenantCache struct {
-	LastRunTimestamp string
-	Servers          []servers.Server
-}
-
-// Cache for all tenants
-var otcCache map[string]otcTenantCache
-
 func getAllServers(username string) ([]servers.Server, error) {
 	clients, err := getComputeClients()
 	if err != nil {
 		return nil, err
 	}
+	log.WithFields(log.Fields{
+		"username": username,
+	}).Debug("Getting EC Servers.")
+
 	var allServers []servers.Server
-	for _, client := range clients {
-		serversInTenant, err := getServers(client, username)
+	for domain, client := range clients {
+		serversInTenant, err := otcCache.getServers(domain, client)
 		if err != nil {
 			return nil, err
 		}
@@ -399,46 +239,6 @@ func getAllServers(username string) ([]servers.Server, error) {
 	return allServers, nil
 }
 
-func getServers(client *gophercloud.ServiceClient, username string) ([]servers.Server, error) {
-	if otcCache == nil {
-		otcCache = make(map[string]otcTenantCache)
-	}
-	log.WithFields(log.Fields{
-		"username": username,
-	}).Debug("Getting EC Servers.")
-
-	cacheKey := client.Endpoint
-	// this seems to work even if lastRunTimestamp is empty
-	opts := servers.ListOpts{
-		ChangesSince: otcCache[cacheKey].LastRunTimestamp,
-	}
-
-	allPages, err := servers.List(client, opts).AllPages()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"username": username,
-			"err":      err.Error(),
-		}).Error("Error while listing servers")
-		return nil, fmt.Errorf(genericOTCAPIError)
-	}
-
-	newServers, err := servers.ExtractServers(allPages)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"username": username,
-			"err":      err.Error(),
-		}).Error("Error while extracting servers")
-		return nil, fmt.Errorf(genericOTCAPIError)
-	}
-
-	otcCache[cacheKey] = otcTenantCache{
-		LastRunTimestamp: time.Now().Format(time.RFC3339),
-		Servers:          mergeServers(otcCache[cacheKey].Servers, newServers),
-	}
-
-	return otcCache[cacheKey].Servers, nil
-}
-
 func filterServersByUsername(username string, s []servers.Server, showall bool) ([]servers.Server, error) {
 	groups, err := getGroups(username)
 	if err != nil {
@@ -463,17 +263,7 @@ func filterServersByUsername(username string, s []servers.Server, showall bool)
 }
 
 func getGroups(username string) ([]string, error) {
-	l, err := ldap.New()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"username": username,
-			"err":      err.Error(),
-		}).Error("Error creating ldap object")
-		return nil, fmt.Errorf(genericOTCAPIError)
-	}
-	defer l.Close()
-
-	groups, err := l.GetGroupsOfUser(username)
+	groups, err := ldap.GetGroups(username)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"username": username,
@@ -484,22 +274,6 @@ func getGroups(username string) ([]string, error) {
 	return groups, nil
 }
 
-func mergeServers(cachedServers, newServers []servers.Server) []servers.Server {
-	unique := make(map[string]servers.Server)
-
-	for _, s := range cachedServers {
-		unique[s.ID] = s
-	}
-	for _, s := range newServers {
-		unique[s.ID] = s
-	}
-	final := make([]servers.Server, 0)
-	for _, s := range unique {
-		final = append(final, s)
-	}
-	return final
-}
-
 func getVolumesByServerID(client *gophercloud.ServiceClient, serverId string) ([]volumes.Volume, error) {
 	var result []volumes.Volume
 
@@ -0,0 +1,180 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ecsActionAccepted = "accepted"
+	ecsActionFailed   = "failed"
+	// Seconds to wait for the target state, if wait is set
+	ecsActionTimeout = 300
+)
+
+// ecsAction is a bulk action on servers
+type ecsAction struct {
+	name string
+	run  func(client *gophercloud.ServiceClient, id string) error
+	// Status of the server after the action
+	targetStatus string
+}
+
+func stopECSHandler(c *gin.Context) {
+	runECSActionHandler(c, ecsAction{
+		name: "stop",
+		run: func(client *gophercloud.ServiceClient, id string) error {
+			return startstop.Stop(client, id).Err
+		},
+		targetStatus: "SHUTOFF",
+	})
+}
+
+func startECSHandler(c *gin.Context) {
+	runECSActionHandler(c, ecsAction{
+		name: "start",
+		run: func(client *gophercloud.ServiceClient, id string) error {
+			return startstop.Start(client, id).Err
+		},
+		targetStatus: "ACTIVE",
+	})
+}
+
+func rebootECSHandler(c *gin.Context) {
+	runECSActionHandler(c, ecsAction{
+		name:         "reboot",
+		targetStatus: "ACTIVE",
+	})
+}
+
+// runECSActionHandler runs the action on all servers concurrently and
+// returns the result of every server
+func runECSActionHandler(c *gin.Context, action ecsAction) {
+	username := common.GetUserName(c)
+
+	var data ECSActionCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if len(data.Servers) == 0 {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "No servers selected"})
+		return
+	}
+	if action.name == "reboot" {
+		rebootType, err := getRebootType(data.Type)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+		action.run = func(client *gophercloud.ServiceClient, id string) error {
+			return servers.Reboot(client, id, &servers.RebootOpts{Type: rebootType}).Err
+		}
+	}
+	if err := validatePermissions(data.Servers, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	clients, err := getComputeClients()
+	if err != nil {
+		log.Printf("Error getting compute clients: %v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+		"action":   action.name,
+		"servers":  len(data.Servers),
+		"wait":     data.Wait,
+	}).Info("Running ECS action @ OTC.")
+
+	results := runECSAction(clients, data.Servers, action, data.Wait)
+	for _, r := range results {
+		if r.Status == ecsActionFailed {
+			c.JSON(http.StatusBadRequest, ECSActionResponse{
+				Message: fmt.Sprintf("At least one server couldn't be %v.", ecsActionPastTense(action.name)),
+				Results: results,
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, ECSActionResponse{
+		Message: fmt.Sprintf("Server %v initiated.", action.name),
+		Results: results,
+	})
+}
+
+func runECSAction(clients map[string]*gophercloud.ServiceClient, untrustedServers []servers.Server, action ecsAction, wait bool) []ECSActionResult {
+	results := make([]ECSActionResult, len(untrustedServers))
+	var wg sync.WaitGroup
+	for i, server := range untrustedServers {
+		wg.Add(1)
+		go func(i int, server servers.Server) {
+			defer wg.Done()
+			results[i] = ECSActionResult{ID: server.ID, Name: server.Name, Status: ecsActionAccepted}
+			if err := runECSActionOnServer(clients, server, action, wait); err != nil {
+				results[i].Status = ecsActionFailed
+				results[i].Reason = err.Error()
+			}
+		}(i, server)
+	}
+	wg.Wait()
+	return results
+}
+
+func runECSActionOnServer(clients map[string]*gophercloud.ServiceClient, server servers.Server, action ecsAction, wait bool) error {
+	logger := log.WithFields(log.Fields{
+		"action": action.name,
+		"server": server.ID,
+		"name":   server.Name,
+	})
+	client, ok := clients[getTenantName(server.Name)]
+	if !ok {
+		logger.Error("No tenant found for server")
+		return fmt.Errorf("No tenant found for server %v", server.Name)
+	}
+	if err := action.run(client, server.ID); err != nil {
+		logger.WithField("err", err.Error()).Error("Error while running ECS action")
+		return fmt.Errorf("The server couldn't be %v", ecsActionPastTense(action.name))
+	}
+	if !wait {
+		return nil
+	}
+	if err := servers.WaitForStatus(client, server.ID, action.targetStatus, ecsActionTimeout); err != nil {
+		logger.WithField("err", err.Error()).Error("Error while waiting for the server status")
+		return fmt.Errorf("The server didn't reach the status %v", action.targetStatus)
+	}
+	return nil
+}
+
+func getRebootType(rebootType string) (servers.RebootMethod, error) {
+	switch strings.ToLower(rebootType) {
+	case "", "soft":
+		return servers.SoftReboot, nil
+	case "hard":
+		return servers.HardReboot, nil
+	}
+	return "", fmt.Errorf("Wrong API usage. Parameter type is: %v. Should be one of: soft, hard", rebootType)
+}
+
+func ecsActionPastTense(action string) string {
+	switch action {
+	case "stop":
+		return "stopped"
+	case "start":
+		return "started"
+	}
+	return action + "ed"
+}
@@ -0,0 +1,74 @@
+package otc
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+)
+
+func TestRunECSAction(t *testing.T) {
+	setTestTenants()
+	clients := map[string]*gophercloud.ServiceClient{
+		"SBB_RZ_T_001": {},
+	}
+	var calls int32
+	action := ecsAction{
+		name: "stop",
+		run: func(client *gophercloud.ServiceClient, id string) error {
+			atomic.AddInt32(&calls, 1)
+			if id == "broken" {
+				return fmt.Errorf("error")
+			}
+			return nil
+		},
+	}
+	untrustedServers := []servers.Server{
+		{ID: "1", Name: "xyzt01.sbb.ch"},
+		{ID: "broken", Name: "xyzt02.sbb.ch"},
+		{ID: "3", Name: "xyzp01.sbb.ch"},
+		{ID: "4", Name: "xyzt03.sbb.ch"},
+	}
+
+	results := runECSAction(clients, untrustedServers, action, false)
+	expected := []string{ecsActionAccepted, ecsActionFailed, ecsActionFailed, ecsActionAccepted}
+	for i, r := range results {
+		if r.ID != untrustedServers[i].ID {
+			t.Errorf("ERROR! result %v: expected id %v, got %v", i, untrustedServers[i].ID, r.ID)
+		}
+		if r.Status != expected[i] {
+			t.Errorf("ERROR! result %v: expected status %v, got %v", i, expected[i], r.Status)
+		}
+		if r.Status == ecsActionFailed && r.Reason == "" {
+			t.Errorf("ERROR! result %v: reason is missing", i)
+		}
+	}
+	// the server without client is skipped
+	if calls != 3 {
+		t.Errorf("ERROR! expected 3 calls, got %v", calls)
+	}
+}
+
+func TestGetRebootType(t *testing.T) {
+	var testsets = []struct {
+		rebootType string
+		expected   servers.RebootMethod
+		expectErr  bool
+	}{
+		{"", servers.SoftReboot, false},
+		{"soft", servers.SoftReboot, false},
+		{"HARD", servers.HardReboot, false},
+		{"reset", "", true},
+	}
+	for _, tt := range testsets {
+		rebootType, err := getRebootType(tt.rebootType)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! type %v: expected error: %v, got: %v", tt.rebootType, tt.expectErr, err)
+		}
+		if rebootType != tt.expected {
+			t.Errorf("ERROR! type %v: expected %v, got %v", tt.rebootType, tt.expected, rebootType)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/snapshots"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Metadata of servers with a scheduled deletion
+	deletionDateKey     = "ssp_deletion_date"
+	deletionSnapshotKey = "ssp_deletion_snapshot"
+	deletionUserKey     = "ssp_deletion_user"
+
+	maxDeletionDays           = 90
+	scheduledDeletionInterval = time.Hour
+	// Maximum time to wait for a volume to be attached or detached
+	volumeStatusTimeout = 5 * 60
+)
+
+func deleteECSHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	params := c.Request.URL.Query()
+	snapshot := false
+	if params.Get("snapshot") != "" {
+		var err error
+		snapshot, err = strconv.ParseBool(params.Get("snapshot"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+			return
+		}
+	}
+	days := 0
+	if params.Get("days") != "" {
+		var err error
+		days, err = strconv.Atoi(params.Get("days"))
+		if err != nil || days < 0 || days > maxDeletionDays {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Days must be between 0 and %v", maxDeletionDays)})
+			return
+		}
+	}
+	if days > 0 && !config.Config().GetBool("uos_scheduled_deletion_enabled") {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Scheduled deletion is not enabled"})
+		return
+	}
+
+	if err := validatePermissions([]servers.Server{{ID: id}}, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	client, server, err := getServerByID(id, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if server.Status != "SHUTOFF" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The server must be stopped before it can be deleted"})
+		return
+	}
+
+	if days > 0 {
+		deletionDate := time.Now().AddDate(0, 0, days)
+		log.WithFields(log.Fields{
+			"username": username,
+			"server":   server.ID,
+			"date":     deletionDate,
+		}).Info("Scheduling deletion of ECS @ OTC.")
+		opts := servers.MetadataOpts{
+			deletionDateKey:     deletionDate.UTC().Format(time.RFC3339),
+			deletionSnapshotKey: strconv.FormatBool(snapshot),
+			deletionUserKey:     username,
+		}
+		if _, err := servers.UpdateMetadata(client, server.ID, opts).Extract(); err != nil {
+			log.Printf("Error while scheduling deletion of server %v: %v", server.ID, err)
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+			return
+		}
+		c.JSON(http.StatusOK, common.ApiResponse{Message: fmt.Sprintf("The server will be deleted on %v", deletionDate.Format("02.01.2006"))})
+		return
+	}
+
+	op := newOperation("delete_ecs", server.ID, username)
+	go func() {
+		err := deleteECS(client, server, snapshot, username)
+		if err != nil {
+			finishOperation(op.ID, err, err.Error())
+			return
+		}
+		finishOperation(op.ID, nil, "The server has been deleted")
+	}()
+
+	c.JSON(http.StatusAccepted, op)
+}
+
+func cancelECSDeletionHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	if err := validatePermissions([]servers.Server{{ID: id}}, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	client, server, err := getServerByID(id, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if server.Metadata[deletionDateKey] == "" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The server has no scheduled deletion"})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+		"server":   server.ID,
+	}).Info("Cancelling deletion of ECS @ OTC.")
+	for _, key := range []string{deletionDateKey, deletionSnapshotKey, deletionUserKey} {
+		if err := servers.DeleteMetadatum(client, server.ID, key).ExtractErr(); err != nil {
+			log.Printf("Error while deleting metadata %v of server %v: %v", key, server.ID, err)
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "The deletion has been cancelled"})
+}
+
+// getServerByID returns the current state of the server and the compute client of its tenant
+func getServerByID(id string, username string) (*gophercloud.ServiceClient, *servers.Server, error) {
+	allServers, err := getAllServers(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	tenant := ""
+	for _, s := range allServers {
+		if s.ID == id {
+			tenant = getTenantName(s.Name)
+			break
+		}
+	}
+	if tenant == "" {
+		log.WithFields(log.Fields{
+			"username": username,
+			"server":   id,
+		}).Error("No server found with that id")
+		return nil, nil, fmt.Errorf(genericOTCAPIError)
+	}
+	client, err := getComputeClient(tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := servers.Get(client, id).Extract()
+	if err != nil {
+		log.Printf("Error while getting server %v: %v", id, err)
+		return nil, nil, fmt.Errorf(genericOTCAPIError)
+	}
+	return client, server, nil
+}
+
+// deleteECS deletes a stopped server and its key pair, if it was created by the SSP.
+// If snapshot is true, the data volumes are detached and a snapshot is created.
+// These volumes are kept, because OTC doesn't allow to delete volumes with snapshots.
+func deleteECS(client *gophercloud.ServiceClient, server *servers.Server, snapshot bool, username string) error {
+	logger := log.WithFields(log.Fields{
+		"username": username,
+		"server":   server.ID,
+		"name":     server.Name,
+	})
+	logger.Info("Deleting ECS @ OTC.")
+
+	if snapshot {
+		if err := snapshotDataVolumes(client, server, logger); err != nil {
+			return err
+		}
+	}
+
+	if err := servers.Delete(client, server.ID).ExtractErr(); err != nil {
+		logger.Errorf("Error while deleting server: %v", err)
+		return fmt.Errorf("The server couldn't be deleted. Please create a ticket")
+	}
+
+	otcCache.markStale(getTenantName(server.Name))
+
+	if strings.HasPrefix(server.KeyName, sspKeyPairPrefix) {
+		if err := keypairs.Delete(client, server.KeyName).ExtractErr(); err != nil {
+			// the server is already deleted, so this is not returned as an error
+			logger.Errorf("Error while deleting key pair %v: %v", server.KeyName, err)
+		}
+	}
+	return nil
+}
+
+func snapshotDataVolumes(client *gophercloud.ServiceClient, server *servers.Server, logger *log.Entry) error {
+	blockStorageClient, err := getBlockStorageClient(getTenantName(server.Name))
+	if err != nil {
+		return err
+	}
+	attachedVolumes, err := getVolumesByServerID(blockStorageClient, server.ID)
+	if err != nil {
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	for _, volume := range attachedVolumes {
+		if volume.Bootable == "true" {
+			continue
+		}
+		logger.Infof("Detaching volume %v", volume.ID)
+		if err := volumeattach.Delete(client, server.ID, volume.ID).ExtractErr(); err != nil {
+			logger.Errorf("Error while detaching volume %v: %v", volume.ID, err)
+			return fmt.Errorf("The data volumes couldn't be detached. Please create a ticket")
+		}
+		if err := waitForVolumeStatus(blockStorageClient, volume.ID, "available", volumeStatusTimeout); err != nil {
+			logger.Errorf("Error while waiting for volume %v: %v", volume.ID, err)
+			return fmt.Errorf("The data volumes couldn't be detached. Please create a ticket")
+		}
+
+		opts := snapshots.CreateOpts{
+			VolumeID:    volume.ID,
+			Name:        fmt.Sprintf("%v-%v", server.Name, time.Now().Format("20060102")),
+			Description: fmt.Sprintf("Created by the SSP before deleting server %v (%v)", server.Name, server.ID),
+		}
+		s, err := snapshots.Create(blockStorageClient, opts).Extract()
+		if err != nil {
+			logger.Errorf("Error while creating snapshot of volume %v: %v", volume.ID, err)
+			return fmt.Errorf("The snapshot couldn't be created. Please create a ticket")
+		}
+		logger.Infof("Created snapshot %v of volume %v", s.ID, volume.ID)
+	}
+	return nil
+}
+
+func waitForVolumeStatus(client *gophercloud.ServiceClient, id string, status string, secs int) error {
+	return gophercloud.WaitFor(secs, func() (bool, error) {
+		volume, err := volumes.Get(client, id).Extract()
+		if err != nil {
+			return false, err
+		}
+		return volume.Status == status, nil
+	})
+}
+
+// StartScheduledECSDeletion deletes the servers with a scheduled deletion date
+// in the past. It runs in the background until the server stops.
+func StartScheduledECSDeletion() {
+	if !config.Config().GetBool("uos_scheduled_deletion_enabled") {
+		log.Println("Scheduled ECS deletion is disabled")
+		return
+	}
+	log.Println("Starting scheduled ECS deletion")
+	go func() {
+		for now := range time.Tick(scheduledDeletionInterval) {
+			runScheduledECSDeletion(now)
+		}
+	}()
+}
+
+func runScheduledECSDeletion(now time.Time) {
+	clients, err := getComputeClients()
+	if err != nil {
+		log.Printf("Scheduled ECS deletion: error getting compute clients: %v", err)
+		return
+	}
+	for tenant, client := range clients {
+		allServers, err := listServers(client, servers.ListOpts{})
+		if err != nil {
+			log.Printf("Scheduled ECS deletion: error listing servers in %v: %v", tenant, err)
+			continue
+		}
+		for i := range allServers {
+			server := &allServers[i]
+			if !isDeletionDue(server.Metadata, now) {
+				continue
+			}
+			username := server.Metadata[deletionUserKey]
+			// The user who scheduled the deletion must still have access to the server
+			if err := validatePermissions([]servers.Server{*server}, username); err != nil {
+				log.Printf("Scheduled ECS deletion: %v has no access to server %v anymore. Skipping", username, server.ID)
+				continue
+			}
+			// A server which has been started again is probably still in use
+			if server.Status != "SHUTOFF" {
+				log.Printf("Scheduled ECS deletion: server %v is not stopped. Skipping", server.ID)
+				continue
+			}
+			snapshot, _ := strconv.ParseBool(server.Metadata[deletionSnapshotKey])
+			if err := deleteECS(client, server, snapshot, username); err != nil {
+				log.Printf("Scheduled ECS deletion: error deleting server %v: %v", server.ID, err)
+			}
+		}
+	}
+}
+
+// isDeletionDue returns true, if the metadata contains a deletion date before now
+func isDeletionDue(metadata map[string]string, now time.Time) bool {
+	if metadata[deletionDateKey] == "" {
+		return false
+	}
+	date, err := time.Parse(time.RFC3339, metadata[deletionDateKey])
+	if err != nil {
+		return false
+	}
+	return !date.After(now)
+}
@@ -0,0 +1,29 @@
+package otc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsDeletionDue(t *testing.T) {
+	now := time.Date(2020, 5, 10, 12, 0, 0, 0, time.UTC)
+	var testsets = []struct {
+		metadata map[string]string
+		expected bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{deletionDateKey: ""}, false},
+		{map[string]string{deletionDateKey: "invalid"}, false},
+		{map[string]string{deletionDateKey: "2020-05-10T11:00:00Z"}, true},
+		{map[string]string{deletionDateKey: "2020-05-10T12:00:00Z"}, true},
+		{map[string]string{deletionDateKey: "2020-05-10T13:00:00Z"}, false},
+		{map[string]string{deletionDateKey: "2020-05-10T13:00:00+02:00"}, true},
+	}
+
+	for _, tt := range testsets {
+		due := isDeletionDue(tt.metadata, now)
+		if due != tt.expected {
+			t.Errorf("ERROR! %v: expected %v, got %v", tt.metadata, tt.expected, due)
+		}
+	}
+}
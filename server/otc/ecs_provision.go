@@ -0,0 +1,342 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Key pairs created by the SSP have this prefix, so that they can be removed with the server
+	sspKeyPairPrefix = "ssp-"
+	// Maximum time to wait for a new server to become active
+	serverBuildTimeout = 30 * 60
+)
+
+type imageConfig struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+func createECSHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	var data NewECSCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	log.WithFields(log.Fields{
+		"username": username,
+		"data":     data,
+	}).Info("Creating ECS @ OTC.")
+
+	if err := validateUOSGroup(data.UOSGroup, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	tenant, err := validateNewECS(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	client, err := getComputeClient(tenant)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if err := validateFlavor(client, data.FlavorName); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if err := validateVolumeTypes(tenant, data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	image, err := getImageByName(tenant, data.ImageId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.RootDiskSize < image.MinDiskGigabytes {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("The root disk must be at least %v GB", image.MinDiskGigabytes)})
+		return
+	}
+
+	server, err := createECS(client, tenant, data, image.ID, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	otcCache.markStale(tenant)
+	op := newOperation("create_ecs", server.ID, username)
+	go waitForServerBuild(client, server.ID, op.ID)
+
+	c.JSON(http.StatusAccepted, op)
+}
+
+// validateUOSGroup checks if the user is a member of the group
+// which is set as uos_group on the new server
+func validateUOSGroup(group string, username string) error {
+	if group == "" {
+		return fmt.Errorf("Group must be provided")
+	}
+	groups, err := getGroups(username)
+	if err != nil {
+		return err
+	}
+	if common.ContainsStringI(groups, "DG_RBT_UOS_ADMINS") || common.ContainsStringI(groups, group) {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"username": username,
+		"groups":   groups,
+		"group":    group,
+	}).Error("uos_group not found in user groups")
+	return fmt.Errorf("You are not a member of the group %v", group)
+}
+
+// validateNewECS checks the command and returns the tenant of the new server
+func validateNewECS(data NewECSCommand) (string, error) {
+	if data.ECSName == "" {
+		return "", fmt.Errorf("Server name must be provided")
+	}
+	tenant := getTenantName(data.ECSName)
+	if tenant == "" {
+		return "", fmt.Errorf("Invalid server name: %v", data.ECSName)
+	}
+	if data.Billing == "" {
+		return "", fmt.Errorf("Billing information must be provided")
+	}
+	if data.PublicKey == "" {
+		return "", fmt.Errorf("Public key must be provided")
+	}
+	if data.FlavorName == "" {
+		return "", fmt.Errorf("Flavor must be provided")
+	}
+	if data.RootVolumeTypeId == "" || data.RootDiskSize <= 0 {
+		return "", fmt.Errorf("Root disk must be provided")
+	}
+	if data.SystemDiskSize < 0 || data.DataDiskSize < 0 {
+		return "", fmt.Errorf("Disk size must be positive")
+	}
+	if (data.SystemDiskSize > 0 && data.SystemVolumeTypeId == "") || (data.DataDiskSize > 0 && data.DataVolumeTypeId == "") {
+		return "", fmt.Errorf("Volume type must be provided")
+	}
+
+	images, err := getConfiguredImages()
+	if err != nil {
+		return "", err
+	}
+	for _, i := range images {
+		if i.Value == data.ImageId {
+			return tenant, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid image: %v", data.ImageId)
+}
+
+func validateFlavor(client *gophercloud.ServiceClient, flavorName string) error {
+	allFlavors, err := getFlavors(client)
+	if err != nil {
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	for _, f := range allFlavors.Flavors {
+		if f.Name == flavorName {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid flavor: %v", flavorName)
+}
+
+func validateVolumeTypes(tenant string, data NewECSCommand) error {
+	client, err := getBlockStorageClient(tenant)
+	if err != nil {
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	volumeTypes, err := getVolumeTypes(client)
+	if err != nil {
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	used := []string{data.RootVolumeTypeId}
+	if data.SystemDiskSize > 0 {
+		used = append(used, data.SystemVolumeTypeId)
+	}
+	if data.DataDiskSize > 0 {
+		used = append(used, data.DataVolumeTypeId)
+	}
+	for _, u := range used {
+		if !containsVolumeType(volumeTypes.VolumeTypes, u) {
+			return fmt.Errorf("Invalid volume type: %v", u)
+		}
+	}
+	return nil
+}
+
+func containsVolumeType(volumeTypes []VolumeType, id string) bool {
+	for _, v := range volumeTypes {
+		if v.Id == id || v.Name == id {
+			return true
+		}
+	}
+	return false
+}
+
+// getConfiguredImages returns the images users are allowed to use (uos.images)
+func getConfiguredImages() ([]imageConfig, error) {
+	images := []imageConfig{}
+	err := config.Config().UnmarshalKey("uos.images", &images)
+	if err != nil {
+		log.Printf("Error getting images: %v", err)
+		return nil, fmt.Errorf(common.ConfigNotSetError)
+	}
+	if len(images) == 0 {
+		log.Printf("Error: no images found in config (uos.images)")
+		return nil, fmt.Errorf(common.ConfigNotSetError)
+	}
+	for _, i := range images {
+		if i.Label == "" || i.Value == "" {
+			log.Printf("Error: missing label or value in image: %+v", i)
+			return nil, fmt.Errorf(common.ConfigNotSetError)
+		}
+	}
+	return images, nil
+}
+
+func getImageByName(tenant string, name string) (*images.Image, error) {
+	client, err := getImageClient(tenant)
+	if err != nil {
+		return nil, fmt.Errorf(genericOTCAPIError)
+	}
+	allPages, err := images.List(client, images.ListOpts{Name: name}).AllPages()
+	if err != nil {
+		log.Println("Error while listing images.", err.Error())
+		return nil, fmt.Errorf(genericOTCAPIError)
+	}
+	allImages, err := images.ExtractImages(allPages)
+	if err != nil {
+		log.Println("Error while extracting images.", err.Error())
+		return nil, fmt.Errorf(genericOTCAPIError)
+	}
+	if len(allImages) == 0 {
+		log.Printf("Error: image %v not found", name)
+		return nil, fmt.Errorf("Image not found: %v", name)
+	}
+	return &allImages[0], nil
+}
+
+func getBlockDevices(data NewECSCommand, imageID string) []bootfromvolume.BlockDevice {
+	blockDevices := []bootfromvolume.BlockDevice{
+		{
+			SourceType:          bootfromvolume.SourceImage,
+			DestinationType:     bootfromvolume.DestinationVolume,
+			UUID:                imageID,
+			BootIndex:           0,
+			VolumeSize:          data.RootDiskSize,
+			VolumeType:          data.RootVolumeTypeId,
+			DeleteOnTermination: true,
+		},
+	}
+	disks := []DataDisk{
+		{DiskSize: data.SystemDiskSize, VolumeTypeId: data.SystemVolumeTypeId},
+		{DiskSize: data.DataDiskSize, VolumeTypeId: data.DataVolumeTypeId},
+	}
+	for _, d := range disks {
+		if d.DiskSize == 0 {
+			continue
+		}
+		blockDevices = append(blockDevices, bootfromvolume.BlockDevice{
+			SourceType:          bootfromvolume.SourceBlank,
+			DestinationType:     bootfromvolume.DestinationVolume,
+			BootIndex:           -1,
+			VolumeSize:          d.DiskSize,
+			VolumeType:          d.VolumeTypeId,
+			DeleteOnTermination: true,
+		})
+	}
+	return blockDevices
+}
+
+func createECS(client *gophercloud.ServiceClient, tenant string, data NewECSCommand, imageID string, username string) (*servers.Server, error) {
+	t, err := getTenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if t.NetworkID == "" {
+		log.Printf("Error: network_id is not set for tenant %v", tenant)
+		return nil, fmt.Errorf(common.ConfigNotSetError)
+	}
+
+	keyPairName := sspKeyPairPrefix + data.ECSName
+	if _, err := createKeyPair(client, keyPairName, data.PublicKey); err != nil {
+		return nil, fmt.Errorf("The key pair couldn't be created. Please check the public key")
+	}
+
+	serverCreateOpts := servers.CreateOpts{
+		Name:             data.ECSName,
+		FlavorName:       data.FlavorName,
+		AvailabilityZone: data.AvailabilityZone,
+		Networks:         []servers.Network{{UUID: t.NetworkID}},
+		Metadata: map[string]string{
+			"uos_group":   data.UOSGroup,
+			"billing":     data.Billing,
+			"mega_id":     data.MegaId,
+			"uos_creator": username,
+		},
+		ServiceClient: client,
+	}
+	createOpts := keypairs.CreateOptsExt{
+		CreateOptsBuilder: bootfromvolume.CreateOptsExt{
+			CreateOptsBuilder: serverCreateOpts,
+			BlockDevice:       getBlockDevices(data, imageID),
+		},
+		KeyName: keyPairName,
+	}
+
+	server, err := bootfromvolume.Create(client, createOpts).Extract()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"username": username,
+			"server":   data.ECSName,
+			"err":      err.Error(),
+		}).Error("Error while creating server")
+		if err := keypairs.Delete(client, keyPairName).ExtractErr(); err != nil {
+			log.Printf("Error while deleting key pair %v: %v", keyPairName, err)
+		}
+		return nil, fmt.Errorf(genericOTCAPIError)
+	}
+	return server, nil
+}
+
+func waitForServerBuild(client *gophercloud.ServiceClient, serverID string, operationID string) {
+	err := gophercloud.WaitFor(serverBuildTimeout, func() (bool, error) {
+		server, err := servers.Get(client, serverID).Extract()
+		if err != nil {
+			return false, err
+		}
+		if server.Status == "ERROR" {
+			return false, fmt.Errorf("Server is in status ERROR: %v", server.Fault.Message)
+		}
+		return server.Status == "ACTIVE", nil
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"server": serverID,
+			"err":    err.Error(),
+		}).Error("Server build failed")
+		finishOperation(operationID, err, "The server couldn't be created. Please create a ticket")
+		return
+	}
+	finishOperation(operationID, nil, "The server has been created")
+}
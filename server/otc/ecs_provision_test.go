@@ -0,0 +1,54 @@
+package otc
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func TestValidateNewECS(t *testing.T) {
+	setTestTenants()
+	config.Config().Set("uos.images", []map[string]string{
+		{"label": "RHEL 7", "value": "Rhel-7-image"},
+	})
+
+	valid := func() NewECSCommand {
+		return NewECSCommand{
+			ECSName:          "xyzt01.sbb.ch",
+			FlavorName:       "s2.medium.4",
+			ImageId:          "Rhel-7-image",
+			Billing:          "12345",
+			PublicKey:        "ssh-rsa AAAA",
+			RootVolumeTypeId: "SSD",
+			RootDiskSize:     20,
+		}
+	}
+	var testsets = []struct {
+		modify         func(*NewECSCommand)
+		expectedTenant string
+		expectErr      bool
+	}{
+		{func(d *NewECSCommand) {}, "SBB_RZ_T_001", false},
+		{func(d *NewECSCommand) { d.ECSName = "xyzp01.sbb.ch" }, "SBB_RZ_P_001", false},
+		{func(d *NewECSCommand) { d.ECSName = "xyzx01.sbb.ch" }, "", true},
+		{func(d *NewECSCommand) { d.ECSName = "" }, "", true},
+		{func(d *NewECSCommand) { d.ImageId = "Windows" }, "", true},
+		{func(d *NewECSCommand) { d.Billing = "" }, "", true},
+		{func(d *NewECSCommand) { d.PublicKey = "" }, "", true},
+		{func(d *NewECSCommand) { d.RootDiskSize = 0 }, "", true},
+		{func(d *NewECSCommand) { d.DataDiskSize = 10 }, "", true},
+		{func(d *NewECSCommand) { d.DataDiskSize = 10; d.DataVolumeTypeId = "SATA" }, "SBB_RZ_T_001", false},
+	}
+
+	for i, tt := range testsets {
+		data := valid()
+		tt.modify(&data)
+		tenant, err := validateNewECS(data)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+		if tenant != tt.expectedTenant {
+			t.Errorf("ERROR! testset %v: expected tenant %v, got %v", i, tt.expectedTenant, tenant)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package otc
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	operationRunning   = "running"
+	operationSucceeded = "succeeded"
+	operationFailed    = "failed"
+
+	// Finished operations are removed after this duration
+	operationRetention = 24 * time.Hour
+)
+
+// Operation tracks a long running action at OTC (e.g. the build of a server),
+// so that the frontend can poll its status.
+type Operation struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Resource string    `json:"resource"`
+	Username string    `json:"username"`
+	Status   string    `json:"status"`
+	Message  string    `json:"message"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+var operations = struct {
+	sync.Mutex
+	m map[string]*Operation
+}{
+	m: make(map[string]*Operation),
+}
+
+func getOperationHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	op, ok := getOperation(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, common.ApiResponse{Message: "Operation not found"})
+		return
+	}
+	if op.Username != username {
+		groups, err := getGroups(username)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+		if !common.ContainsStringI(groups, "DG_RBT_UOS_ADMINS") {
+			log.WithFields(log.Fields{
+				"username":  username,
+				"operation": id,
+			}).Error("Operation belongs to another user")
+			c.JSON(http.StatusNotFound, common.ApiResponse{Message: "Operation not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, op)
+}
+
+// newOperation registers a running operation and returns it
+func newOperation(opType string, resource string, username string) Operation {
+	now := time.Now()
+	op := Operation{
+		ID:       uuid.Must(uuid.NewV4()).String(),
+		Type:     opType,
+		Resource: resource,
+		Username: username,
+		Status:   operationRunning,
+		Created:  now,
+		Updated:  now,
+	}
+
+	operations.Lock()
+	defer operations.Unlock()
+	for id, o := range operations.m {
+		if o.Status != operationRunning && now.Sub(o.Updated) > operationRetention {
+			delete(operations.m, id)
+		}
+	}
+	operations.m[op.ID] = &op
+	return op
+}
+
+// finishOperation sets the final status of an operation. err == nil means success.
+func finishOperation(id string, err error, message string) {
+	operations.Lock()
+	defer operations.Unlock()
+	op, ok := operations.m[id]
+	if !ok {
+		return
+	}
+	op.Status = operationSucceeded
+	op.Message = message
+	if err != nil {
+		op.Status = operationFailed
+	}
+	op.Updated = time.Now()
+}
+
+// getOperation returns a copy of the operation
+func getOperation(id string) (Operation, bool) {
+	operations.Lock()
+	defer operations.Unlock()
+	op, ok := operations.m[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *op, true
+}
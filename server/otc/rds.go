@@ -4,36 +4,46 @@ import (
 	"fmt"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
-	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
 	"github.com/gin-gonic/gin"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/rds/v1/tags"
 	"github.com/gophercloud/gophercloud/openstack/rds/v3/datastores"
 	"github.com/gophercloud/gophercloud/openstack/rds/v3/flavors"
 	"github.com/gophercloud/gophercloud/openstack/rds/v3/instances"
+	"github.com/patrickmn/go-cache"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 )
 
+const (
+	// Used if rds.* is not set
+	defaultRDSListTimeout = 30 * time.Second
+	defaultRDSTagWorkers  = 10
+	defaultRDSTagCacheTTL = 5 * time.Minute
+)
+
+// Tags of the master nodes. Getting them is slow and fails sometimes.
+var rdsTagCache = cache.New(defaultRDSTagCacheTTL, 10*time.Minute)
+
 func listRDSFlavorsHandler(c *gin.Context) {
 	version := c.Request.URL.Query().Get("version_name")
 	if version == "" {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Wrong API usage. Missing parameter version_name"})
 		return
 	}
-	stage := c.Request.URL.Query().Get("stage")
-	if stage == "" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Wrong API usage. Missing parameter stage"})
+	engine, err := getRDSEngine(c.Request.URL.Query().Get("engine"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	if stage != "p" && stage != "t" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Wrong API usage. Parameter stage is: %v. Should be p or t", stage)})
+	t, err := getTenantByStage(c.Request.URL.Query().Get("stage"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	tenant := fmt.Sprintf("SBB_RZ_%v_001", strings.ToUpper(stage))
-	client, err := getRDSClient(tenant)
+	client, err := getRDSClient(t.Domain)
 	if err != nil {
 		log.Println("Error getting rds client.", err.Error())
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
@@ -44,7 +54,7 @@ func listRDSFlavorsHandler(c *gin.Context) {
 		Versionname: version,
 	}
 
-	allPages, err := flavors.List(client, dbFlavorsOpts, "postgresql").AllPages()
+	allPages, err := flavors.List(client, dbFlavorsOpts, engine).AllPages()
 	if err != nil {
 		log.Println("Error while listing flavors.", err.Error())
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "There was a problem getting the available database flavors"})
@@ -63,24 +73,24 @@ func listRDSFlavorsHandler(c *gin.Context) {
 }
 
 func listRDSVersionsHandler(c *gin.Context) {
-	stage := c.Request.URL.Query().Get("stage")
-	if stage == "" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Wrong API usage. Missing parameter stage"})
+	engine, err := getRDSEngine(c.Request.URL.Query().Get("engine"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	if stage != "p" && stage != "t" {
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Wrong API usage. Parameter stage is: %v. Should be p or t", stage)})
+	t, err := getTenantByStage(c.Request.URL.Query().Get("stage"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		return
 	}
-	tenant := fmt.Sprintf("SBB_RZ_%v_001", strings.ToUpper(stage))
-	client, err := getRDSClient(tenant)
+	client, err := getRDSClient(t.Domain)
 	if err != nil {
 		log.Println("Error getting rds client.", err.Error())
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
 		return
 	}
 
-	allPages, err := datastores.List(client, "postgresql").AllPages()
+	allPages, err := datastores.List(client, engine).AllPages()
 	if err != nil {
 		log.Println("Error while listing datastores.", err.Error())
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "There was a problem getting the available database versions"})
@@ -94,8 +104,7 @@ func listRDSVersionsHandler(c *gin.Context) {
 		return
 	}
 
-	cfg := config.Config()
-	versionWhitelist := cfg.GetStringSlice("rds.version_whitelist")
+	versionWhitelist := getRDSVersionWhitelist(engine)
 
 	versions := make([]string, 0)
 
@@ -112,11 +121,24 @@ func listRDSVersionsHandler(c *gin.Context) {
 func listRDSInstancesHandler(c *gin.Context) {
 	username := common.GetUserName(c)
 
-	var response []rdsInstance
-	tenants := []string{
-		"SBB_RZ_T_001",
-		"SBB_RZ_P_001",
+	// The tags are loaded in parallel. After the deadline the instances found so far are returned.
+	deadline := time.Now().Add(getCacheDuration("rds.list_timeout", defaultRDSListTimeout))
+
+	groups, err := getGroups(username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	// Use make because of the following behaviour:
+	// https://github.com/gin-gonic/gin/issues/125
+	result := make([]rdsInstance, 0)
+	tenants, err := getTenantDomains()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
 	}
+	incomplete := 0
 	for _, tenant := range tenants {
 		client, err := getRDSClient(tenant)
 		if err != nil {
@@ -125,73 +147,140 @@ func listRDSInstancesHandler(c *gin.Context) {
 			return
 		}
 
-		instances, err := getRDSInstancesByUsername(client, username)
+		instances, missing, err := getRDSInstancesByUsername(client, username, groups, deadline)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
 			return
 		}
-		response = append(response, instances...)
+		result = append(result, instances...)
+		incomplete += missing
+	}
+	if incomplete > 0 {
+		c.Header(common.WarningHeader, fmt.Sprintf("The tags of %v instances couldn't be loaded. The list may be incomplete.", incomplete))
 	}
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, result)
 	return
 }
 
 type rdsInstance struct {
 	instances.RdsInstanceResponse
-	Tags map[string]string
+	Tags   map[string]string
+	Engine string `json:"engine"`
 }
 
-func getRDSInstancesByUsername(client *gophercloud.ServiceClient, username string) ([]rdsInstance, error) {
+// getRDSInstancesByUsername returns the instances of the rds_groups of the user.
+// It also returns the number of instances whose tags couldn't be loaded before the deadline.
+func getRDSInstancesByUsername(client *gophercloud.ServiceClient, username string, groups []string, deadline time.Time) ([]rdsInstance, int, error) {
 	// Use make because of the following behaviour:
 	// https://github.com/gin-gonic/gin/issues/125
 	filteredInstances := make([]rdsInstance, 0)
-	l, err := ldap.New()
-	if err != nil {
-		return nil, err
-	}
-	defer l.Close()
-
-	groups, err := l.GetGroupsOfUser(username)
-	if err != nil {
-		return nil, err
-	}
 
 	instances, err := getRDSInstances(client)
 	if err != nil {
 		log.Println("Error getting rds client.", err.Error())
-		return nil, err
+		return nil, 0, err
 	}
 
 	clientV1, err := getRDSV1Client(client.ProviderClient)
 	if err != nil {
 		log.Println("Error getting rdsV1 client.", err.Error())
-		return nil, err
+		return nil, 0, err
 	}
 
+	// instance id -> master node id
+	masterNodeIDs := make(map[string]string)
+	var nodeIDs []string
 	for _, instance := range instances {
 		if instance.Type == "slave" {
 			continue
 		}
-		log.Printf("%v", instance.Nodes[0].Id)
 		id, err := getMasterNodeID(instance.Nodes)
 		if err != nil {
 			log.Printf("Error while getting the ID for: %v", instance.Id)
 			continue
 		}
-		t, err := getRDSTags(clientV1, id)
-		if err != nil {
-			continue
-		}
-		if t["rds_group"] == "" {
+		masterNodeIDs[instance.Id] = id
+		nodeIDs = append(nodeIDs, id)
+	}
+
+	allTags, missing := lookupRDSTags(nodeIDs, deadline, func(id string) (map[string]string, error) {
+		return getRDSTags(clientV1, id)
+	})
+
+	for _, instance := range instances {
+		t, ok := allTags[masterNodeIDs[instance.Id]]
+		// Instances, which are being built, have pending tags
+		t, pending := withPendingRDSTags(instance.Id, t)
+		if !ok && !pending {
 			continue
 		}
-		if !common.ContainsStringI(groups, t["rds_group"]) {
+		if !hasRDSAccess(t, groups) {
 			continue
 		}
-		filteredInstances = append(filteredInstances, rdsInstance{instance, t})
+		filteredInstances = append(filteredInstances, rdsInstance{instance, t, getRDSInstanceEngine(instance)})
 		log.Printf("ALLOWED %v %v", username, instance.Id)
 	}
-	return filteredInstances, nil
+	return filteredInstances, missing, nil
+}
+
+// lookupRDSTags calls lookup for every id with rds.tag_workers workers.
+// It returns the tags found before the deadline and the number of ids without tags.
+func lookupRDSTags(ids []string, deadline time.Time, lookup func(id string) (map[string]string, error)) (map[string]map[string]string, int) {
+	type tagResult struct {
+		id   string
+		tags map[string]string
+		err  error
+	}
+
+	workers := config.Config().GetInt("rds.tag_workers")
+	if workers <= 0 {
+		workers = defaultRDSTagWorkers
+	}
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	jobs := make(chan string, len(ids))
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+
+	// Buffered, so that the workers can finish after the deadline.
+	// Their results are still cached for the next request.
+	results := make(chan tagResult, len(ids))
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range jobs {
+				t, err := lookup(id)
+				results <- tagResult{id, t, err}
+			}
+		}()
+	}
+
+	allTags := make(map[string]map[string]string)
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+	for received := 0; received < len(ids); received++ {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				allTags[r.id] = r.tags
+			}
+		case <-timeout.C:
+			log.Printf("Timeout while loading RDS tags. %v of %v received", received, len(ids))
+			return allTags, len(ids) - len(allTags)
+		}
+	}
+	return allTags, len(ids) - len(allTags)
+}
+
+// hasRDSAccess checks if one of the groups matches the rds_group tag of an instance
+func hasRDSAccess(tags map[string]string, groups []string) bool {
+	if tags["rds_group"] == "" {
+		return false
+	}
+	return common.ContainsStringI(groups, tags["rds_group"])
 }
 
 func getMasterNodeID(nodes []instances.Nodes) (string, error) {
@@ -219,10 +308,16 @@ func getRDSInstances(client *gophercloud.ServiceClient) ([]instances.RdsInstance
 }
 
 func getRDSTags(client *gophercloud.ServiceClient, id string) (map[string]string, error) {
+	if cached, ok := rdsTagCache.Get(id); ok {
+		return cached.(map[string]string), nil
+	}
 	var t map[string]string
 	err := retry(5, 5*time.Second, func() error {
 		var err error
-		t, err = tags.GetTags(client, id).Extract()
+		// tags.GetTags changes the endpoint of the client, which is shared by the workers
+		var r tags.GetTagsResult
+		_, r.Err = client.Get(getRDSTagsURL(client, id), &r.Body, nil)
+		t, err = r.Extract()
 		if err != nil {
 			log.Println("Retrying...")
 		}
@@ -232,5 +327,31 @@ func getRDSTags(client *gophercloud.ServiceClient, id string) (map[string]string
 		log.Printf("Error while listing tags for instance: %v. %v", id, err)
 		return nil, err
 	}
+	rdsTagCache.Set(id, t, getCacheDuration("rds.tag_cache_ttl", defaultRDSTagCacheTTL))
 	return t, nil
 }
+
+// getRDSTagsURL maps https://rds.../rds/v1/<tenant>/ to https://rds.../v1/<tenant>/rds/<id>/tags
+func getRDSTagsURL(client *gophercloud.ServiceClient, id string) string {
+	return strings.Replace(client.Endpoint, "rds/", "", 1) + "rds/" + id + "/tags"
+}
+
+// setRDSTag adds a tag to the instance. The SDK only supports reading tags.
+func setRDSTag(client *gophercloud.ServiceClient, id string, key string, value string) error {
+	url := getRDSTagsURL(client, id)
+	body := map[string]interface{}{
+		"tag": map[string]string{
+			"key":   key,
+			"value": value,
+		},
+	}
+	_, err := client.Post(url, body, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{200, 204},
+	})
+	if err != nil {
+		log.Printf("Error while setting tag %v for instance: %v. %v", key, id, err)
+		return err
+	}
+	rdsTagCache.Delete(id)
+	return nil
+}
@@ -0,0 +1,342 @@
+package otc
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/backups"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/instances"
+)
+
+const (
+	// Limits of OTC for automated backups
+	rdsMinBackupKeepDays = 1
+	rdsMaxBackupKeepDays = 732
+)
+
+// The backup window must start at a full hour and last one hour, e.g. 01:00-02:00
+var rdsBackupWindowRegex = regexp.MustCompile(`^([01]\d|2[0-3]):00-([01]\d|2[0-3]):00$`)
+
+func listRDSBackupsHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	instanceBackups, err := getRDSBackups(client, backups.ListBackupsOpts{InstanceId: instance.Id})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	policy, err := backups.GetPolicy(client, instance.Id).Extract()
+	if err != nil {
+		log.Printf("Error while getting backup policy of RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	restoreTimes, err := getRDSRestoreTimes(client, instance.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+
+	c.JSON(http.StatusOK, RDSBackupListResponse{
+		Backups:      instanceBackups,
+		Policy:       policy.ListBackupsPolicy,
+		RestoreTimes: restoreTimes,
+	})
+}
+
+func createRDSBackupHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	var data RDSBackupCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if data.Name == "" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Name must be provided"})
+		return
+	}
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	log.Printf("%v creates backup %v of RDS instance %v @ OTC.", username, data.Name, instance.Id)
+
+	opts := backups.CreateBackupsOpts{
+		InstanceId:  instance.Id,
+		Name:        data.Name,
+		Description: data.Description,
+	}
+	backup, err := backups.Create(client, opts).Extract()
+	if err != nil {
+		log.Printf("Error while creating backup of RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, backup.Backup)
+}
+
+func updateRDSBackupPolicyHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	var data RDSBackupPolicyCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if err := validateRDSBackupPolicy(data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	log.Printf("%v changes backup policy of RDS instance %v to %+v @ OTC.", username, instance.Id, data)
+
+	opts := backups.AutoBackupsPolicyOpts{
+		BackupPolicy: &backups.BackupsPolicy{
+			KeepDays:  &data.KeepDays,
+			StartTime: data.StartTime,
+			Period:    data.Period,
+		},
+	}
+	if err := backups.UpdatePolicy(client, opts, instance.Id).Err; err != nil {
+		log.Printf("Error while updating backup policy of RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "The backup policy has been changed."})
+}
+
+// restoreRDSInstanceHandler restores a backup or a point in time to a new instance.
+// The new instance has the same network, storage and tags as the original instance.
+func restoreRDSInstanceHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	var data RDSRestoreCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	if err := validateRDSRestore(data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	ha := strings.EqualFold(instance.Type, "ha")
+	if data.Flavor == "" {
+		data.Flavor = instance.FlavorRef
+	} else if err := validateRDSFlavor(client, getRDSInstanceEngine(*instance), instance.DataStore.Version, data.Flavor, ha); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	restorePoint := &backups.RestorePoint{InstanceId: instance.Id}
+	if data.BackupID != "" {
+		if err := validateRDSBackup(client, instance.Id, data.BackupID); err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+		restorePoint.Type = "backup"
+		restorePoint.BackupId = data.BackupID
+	} else {
+		restoreTimes, err := getRDSRestoreTimes(client, instance.Id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+			return
+		}
+		if !isRestoreTimeAvailable(data.RestoreTime, restoreTimes) {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The instance can't be restored to this point in time"})
+			return
+		}
+		restorePoint.Type = "timestamp"
+		restorePoint.RestoreTime = int(data.RestoreTime)
+	}
+
+	tags, err := getRDSInstanceTags(client, instance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+
+	log.Printf("%v restores RDS instance %v (%v %v) to new instance %v @ OTC.", username, instance.Id, restorePoint.Type, restorePoint.BackupId, data.Name)
+	opts := backups.RestoreNewRdsOpts{
+		Name:             data.Name,
+		Password:         data.Password,
+		FlavorRef:        data.Flavor,
+		Volume:           &backups.Volume{Type: instance.Volume.Type, Size: instance.Volume.Size},
+		AvailabilityZone: getRDSAvailabilityZones(instance.Nodes),
+		VpcId:            instance.VpcId,
+		SubnetId:         instance.SubnetId,
+		SecurityGroupId:  instance.SecurityGroupId,
+		RestorePoint:     restorePoint,
+	}
+	if ha {
+		replicationMode := instance.Ha.ReplicationMode
+		if replicationMode == "" {
+			replicationMode = rdsEngines[getRDSInstanceEngine(*instance)].ReplicationMode
+		}
+		opts.Ha = &backups.Ha{Mode: "Ha", ReplicationMode: replicationMode}
+	}
+	result, err := backups.Restore(client, opts).Extract()
+	if err != nil {
+		log.Printf("Error while restoring RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+
+	op := newOperation("restore_rds", result.Instance.ID, username)
+	newTags := map[string]string{
+		"rds_group": tags["rds_group"],
+		"billing":   tags["billing"],
+		"creator":   username,
+	}
+	// The instance is tagged by runRDSTagging, when it is active
+	if err := addPendingRDSTags(result.Instance.ID, newTags, op.ID); err != nil {
+		log.Printf("Error saving the tags of RDS instance %v: %v", result.Instance.ID, err)
+		message := fmt.Sprintf("The instance %v has been restored, but its tags couldn't be saved. Please create a ticket", result.Instance.ID)
+		finishOperation(op.ID, err, message)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: message})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, op)
+}
+
+func validateRDSBackupPolicy(data RDSBackupPolicyCommand) error {
+	if data.KeepDays < rdsMinBackupKeepDays || data.KeepDays > rdsMaxBackupKeepDays {
+		return fmt.Errorf("The retention must be between %v and %v days", rdsMinBackupKeepDays, rdsMaxBackupKeepDays)
+	}
+	m := rdsBackupWindowRegex.FindStringSubmatch(data.StartTime)
+	if m == nil {
+		return fmt.Errorf("Invalid backup window: %v. Should be e.g. 01:00-02:00 (UTC)", data.StartTime)
+	}
+	var start, end int
+	fmt.Sscanf(m[1], "%d", &start)
+	fmt.Sscanf(m[2], "%d", &end)
+	if (start+1)%24 != end {
+		return fmt.Errorf("The backup window must be one hour")
+	}
+	if data.Period == "" {
+		return fmt.Errorf("Period must be provided")
+	}
+	for _, day := range strings.Split(data.Period, ",") {
+		if len(day) != 1 || day < "1" || day > "7" {
+			return fmt.Errorf("Invalid period: %v. Should be days of the week, e.g. 1,2,3,4,5,6,7", data.Period)
+		}
+	}
+	return nil
+}
+
+func validateRDSRestore(data RDSRestoreCommand) error {
+	if data.Name == "" {
+		return fmt.Errorf("Name must be provided")
+	}
+	if len(data.Password) < 8 {
+		return fmt.Errorf("The password must be at least 8 characters long")
+	}
+	if (data.BackupID == "") == (data.RestoreTime == 0) {
+		return fmt.Errorf("Either backupId or restoreTime must be provided")
+	}
+	return nil
+}
+
+// isRestoreTimeAvailable checks if the timestamp (milliseconds) is in one of the restore windows
+func isRestoreTimeAvailable(restoreTime int64, restoreTimes []backups.RestoreTime) bool {
+	for _, r := range restoreTimes {
+		if restoreTime >= int64(r.StartTime) && restoreTime <= int64(r.EndTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRDSBackup(client *gophercloud.ServiceClient, instanceID string, backupID string) error {
+	instanceBackups, err := getRDSBackups(client, backups.ListBackupsOpts{InstanceId: instanceID, BackupId: backupID})
+	if err != nil {
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	for _, b := range instanceBackups {
+		if b.Id == backupID && b.InstanceId == instanceID {
+			if b.Status != "COMPLETED" {
+				return fmt.Errorf("The backup is not completed")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Backup %v not found", backupID)
+}
+
+func getRDSBackups(client *gophercloud.ServiceClient, opts backups.ListBackupsOpts) ([]backups.BackupsResp, error) {
+	allPages, err := backups.List(client, opts).AllPages()
+	if err != nil {
+		log.Printf("Error while listing backups of RDS instance %v: %v", opts.InstanceId, err)
+		return nil, err
+	}
+	result, err := backups.ExtractBackups(allPages)
+	if err != nil {
+		log.Printf("Error while extracting backups of RDS instance %v: %v", opts.InstanceId, err)
+		return nil, err
+	}
+	// Use make because of the following behaviour:
+	// https://github.com/gin-gonic/gin/issues/125
+	instanceBackups := make([]backups.BackupsResp, 0, len(result.Backups))
+	return append(instanceBackups, result.Backups...), nil
+}
+
+func getRDSRestoreTimes(client *gophercloud.ServiceClient, id string) ([]backups.RestoreTime, error) {
+	allPages, err := backups.ListRestoreTime(client, nil, id).AllPages()
+	if err != nil {
+		log.Printf("Error while listing restore times of RDS instance %v: %v", id, err)
+		return nil, err
+	}
+	result, err := backups.ExtractRestoreTime(allPages)
+	if err != nil {
+		log.Printf("Error while extracting restore times of RDS instance %v: %v", id, err)
+		return nil, err
+	}
+	restoreTimes := make([]backups.RestoreTime, 0, len(result.RestoreTimeList))
+	return append(restoreTimes, result.RestoreTimeList...), nil
+}
+
+// getRDSAvailabilityZones returns the availability zones of the nodes, master first (e.g. eu-ch-01,eu-ch-02)
+func getRDSAvailabilityZones(nodes []instances.Nodes) string {
+	var zones []string
+	for _, n := range nodes {
+		if n.Role == "master" {
+			zones = append([]string{n.AvailabilityZone}, zones...)
+		} else {
+			zones = append(zones, n.AvailabilityZone)
+		}
+	}
+	return strings.Join(zones, ",")
+}
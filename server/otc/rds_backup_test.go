@@ -0,0 +1,78 @@
+package otc
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/backups"
+)
+
+func TestValidateRDSBackupPolicy(t *testing.T) {
+	var testsets = []struct {
+		policy    RDSBackupPolicyCommand
+		expectErr bool
+	}{
+		{RDSBackupPolicyCommand{7, "01:00-02:00", "1,2,3,4,5,6,7"}, false},
+		{RDSBackupPolicyCommand{732, "23:00-00:00", "1"}, false},
+		{RDSBackupPolicyCommand{0, "01:00-02:00", "1"}, true},
+		{RDSBackupPolicyCommand{733, "01:00-02:00", "1"}, true},
+		{RDSBackupPolicyCommand{7, "01:00-03:00", "1"}, true},
+		{RDSBackupPolicyCommand{7, "01:30-02:30", "1"}, true},
+		{RDSBackupPolicyCommand{7, "24:00-01:00", "1"}, true},
+		{RDSBackupPolicyCommand{7, "", "1"}, true},
+		{RDSBackupPolicyCommand{7, "01:00-02:00", ""}, true},
+		{RDSBackupPolicyCommand{7, "01:00-02:00", "1,8"}, true},
+		{RDSBackupPolicyCommand{7, "01:00-02:00", "1,,2"}, true},
+	}
+
+	for i, tt := range testsets {
+		err := validateRDSBackupPolicy(tt.policy)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestValidateRDSRestore(t *testing.T) {
+	var testsets = []struct {
+		data      RDSRestoreCommand
+		expectErr bool
+	}{
+		{RDSRestoreCommand{Name: "db", BackupID: "b1", Password: "secret123"}, false},
+		{RDSRestoreCommand{Name: "db", RestoreTime: 1590000000000, Password: "secret123"}, false},
+		{RDSRestoreCommand{Name: "db", BackupID: "b1", RestoreTime: 1590000000000, Password: "secret123"}, true},
+		{RDSRestoreCommand{Name: "db", Password: "secret123"}, true},
+		{RDSRestoreCommand{BackupID: "b1", Password: "secret123"}, true},
+		{RDSRestoreCommand{Name: "db", BackupID: "b1", Password: "short"}, true},
+	}
+
+	for i, tt := range testsets {
+		err := validateRDSRestore(tt.data)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestIsRestoreTimeAvailable(t *testing.T) {
+	restoreTimes := []backups.RestoreTime{
+		{StartTime: 1000, EndTime: 2000},
+		{StartTime: 3000, EndTime: 4000},
+	}
+	var testsets = []struct {
+		restoreTime int64
+		expected    bool
+	}{
+		{1000, true},
+		{1500, true},
+		{4000, true},
+		{2500, false},
+		{999, false},
+		{4001, false},
+	}
+
+	for _, tt := range testsets {
+		if isRestoreTimeAvailable(tt.restoreTime, restoreTimes) != tt.expected {
+			t.Errorf("ERROR! restore time %v: expected %v", tt.restoreTime, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/instances"
+)
+
+// Used if the engine parameter is empty
+const defaultRDSEngine = "postgresql"
+
+type rdsEngine struct {
+	// Datastore type of new instances
+	Datastore string
+	// Replication mode of HA instances
+	ReplicationMode string
+}
+
+// rdsEngines are the engines supported by the SSP. They are enabled in rds.engines.
+var rdsEngines = map[string]rdsEngine{
+	"postgresql": {Datastore: "PostgreSQL", ReplicationMode: "async"},
+	"mysql":      {Datastore: "MySQL", ReplicationMode: "async"},
+	"sqlserver":  {Datastore: "SQLServer", ReplicationMode: "sync"},
+}
+
+func listRDSEnginesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, getRDSEngines())
+}
+
+// getRDSEngines returns the enabled engines (keys of rds.engines).
+// Without config only PostgreSQL is enabled.
+func getRDSEngines() []string {
+	engines := make([]string, 0)
+	for engine := range config.Config().GetStringMap("rds.engines") {
+		if _, ok := rdsEngines[engine]; ok {
+			engines = append(engines, engine)
+		}
+	}
+	if len(engines) == 0 {
+		return []string{defaultRDSEngine}
+	}
+	sort.Strings(engines)
+	return engines
+}
+
+// getRDSEngine validates the engine parameter and returns the engine in lowercase
+func getRDSEngine(engine string) (string, error) {
+	if engine == "" {
+		return defaultRDSEngine, nil
+	}
+	engines := getRDSEngines()
+	if !common.ContainsStringI(engines, engine) {
+		return "", fmt.Errorf("Wrong API usage. Parameter engine is: %v. Should be one of: %v", engine, strings.Join(engines, ", "))
+	}
+	return strings.ToLower(engine), nil
+}
+
+// getRDSVersionWhitelist returns rds.engines.<engine>.version_whitelist.
+// For PostgreSQL rds.version_whitelist is used, if the engine is not configured.
+func getRDSVersionWhitelist(engine string) []string {
+	cfg := config.Config()
+	key := "rds.engines." + engine + ".version_whitelist"
+	if engine == defaultRDSEngine && !cfg.IsSet(key) {
+		return cfg.GetStringSlice("rds.version_whitelist")
+	}
+	return cfg.GetStringSlice(key)
+}
+
+func getRDSInstanceEngine(instance instances.RdsInstanceResponse) string {
+	return strings.ToLower(instance.DataStore.Type)
+}
@@ -0,0 +1,61 @@
+package otc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func TestGetRDSEngine(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("rds.engines", map[string]interface{}{
+		"postgresql": map[string]interface{}{"version_whitelist": []string{"11"}},
+		"mysql":      map[string]interface{}{"version_whitelist": []string{"5.7", "8.0"}},
+		"oracle":     map[string]interface{}{},
+	})
+
+	if engines := getRDSEngines(); !reflect.DeepEqual(engines, []string{"mysql", "postgresql"}) {
+		t.Errorf("ERROR! unexpected engines: %v", engines)
+	}
+
+	var testsets = []struct {
+		engine    string
+		expected  string
+		expectErr bool
+	}{
+		{"", "postgresql", false},
+		{"mysql", "mysql", false},
+		{"MySQL", "mysql", false},
+		{"sqlserver", "", true},
+		{"oracle", "", true},
+	}
+	for _, tt := range testsets {
+		engine, err := getRDSEngine(tt.engine)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! engine %v: expected error: %v, got: %v", tt.engine, tt.expectErr, err)
+		}
+		if engine != tt.expected {
+			t.Errorf("ERROR! engine %v: expected %v, got %v", tt.engine, tt.expected, engine)
+		}
+	}
+
+	if whitelist := getRDSVersionWhitelist("mysql"); !reflect.DeepEqual(whitelist, []string{"5.7", "8.0"}) {
+		t.Errorf("ERROR! unexpected mysql whitelist: %v", whitelist)
+	}
+}
+
+func TestGetRDSEngineDefault(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("rds.version_whitelist", []string{"10", "11"})
+
+	if engines := getRDSEngines(); !reflect.DeepEqual(engines, []string{"postgresql"}) {
+		t.Errorf("ERROR! unexpected engines: %v", engines)
+	}
+	if _, err := getRDSEngine("mysql"); err == nil {
+		t.Errorf("ERROR! mysql should not be enabled")
+	}
+	if whitelist := getRDSVersionWhitelist("postgresql"); !reflect.DeepEqual(whitelist, []string{"10", "11"}) {
+		t.Errorf("ERROR! unexpected postgresql whitelist: %v", whitelist)
+	}
+}
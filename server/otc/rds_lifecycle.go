@@ -0,0 +1,422 @@
+package otc
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/datastores"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/flavors"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/instances"
+	"github.com/gophercloud/gophercloud/openstack/rds/v3/storagetype"
+)
+
+const (
+	// Used if rds.min_volume_gb is not set. OTC doesn't allow smaller RDS volumes.
+	defaultRDSMinVolumeGB = 40
+	// RDS volumes can only be changed in steps of 10 GB
+	rdsVolumeStepGB = 10
+	// Maximum time to wait for a new instance to become active
+	rdsBuildTimeout      = 60 * time.Minute
+	rdsBuildPollInterval = 30 * time.Second
+)
+
+// restartRDSInstanceOpts builds the body {"restart": {}}, which is not possible with instances.RestartRdsInstanceOpts
+type restartRDSInstanceOpts struct{}
+
+func (opts restartRDSInstanceOpts) ToRestartRdsInstanceMap() (map[string]interface{}, error) {
+	return map[string]interface{}{"restart": map[string]interface{}{}}, nil
+}
+
+func createRDSInstanceHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	var data NewRDSInstanceCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	log.Printf("%v creates RDS instance %v (version: %v, flavor: %v) @ OTC.", username, data.Name, data.Version, data.Flavor)
+
+	groups, err := getGroups(username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.RDSGroup == "" || !common.ContainsStringI(groups, data.RDSGroup) {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: fmt.Sprintf("You are not a member of the group %v", data.RDSGroup)})
+		return
+	}
+	engine, err := getRDSEngine(data.Engine)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	data.Engine = engine
+	if err := validateNewRDSInstance(data); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	t, err := getTenantByStage(data.Stage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if t.RDSVPCID == "" || t.RDSSubnetID == "" || t.RDSSecurityGroupID == "" {
+		log.Printf("Error: rds_vpc_id, rds_subnet_id or rds_security_group_id is not set for tenant %v", t.Domain)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: common.ConfigNotSetError})
+		return
+	}
+	client, err := getRDSClient(t.Domain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if err := validateRDSVersion(client, data.Engine, data.Version); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if err := validateRDSFlavor(client, data.Engine, data.Version, data.Flavor, data.HA); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if err := validateRDSVolumeType(client, data.Engine, data.Version, data.VolumeType); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	opts := instances.CreateRdsOpts{
+		Name: data.Name,
+		Datastore: instances.Datastore{
+			Type:    rdsEngines[data.Engine].Datastore,
+			Version: data.Version,
+		},
+		Password:         data.Password,
+		FlavorRef:        data.Flavor,
+		Volume:           &instances.Volume{Type: data.VolumeType, Size: data.VolumeSize},
+		Region:           t.Region,
+		AvailabilityZone: data.AvailabilityZone,
+		VpcId:            t.RDSVPCID,
+		SubnetId:         t.RDSSubnetID,
+		SecurityGroupId:  t.RDSSecurityGroupID,
+	}
+	if data.HA {
+		opts.Ha = &instances.Ha{Mode: "Ha", ReplicationMode: rdsEngines[data.Engine].ReplicationMode}
+	}
+	result, err := instances.Create(client, opts).Extract()
+	if err != nil {
+		log.Printf("Error while creating RDS instance %v: %v", data.Name, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+
+	op := newOperation("create_rds", result.Instance.Id, username)
+	tags := map[string]string{
+		"rds_group": data.RDSGroup,
+		"billing":   data.Billing,
+		"creator":   username,
+	}
+	// The instance is tagged by runRDSTagging, when it is active
+	if err := addPendingRDSTags(result.Instance.Id, tags, op.ID); err != nil {
+		log.Printf("Error saving the tags of RDS instance %v: %v", result.Instance.Id, err)
+		message := fmt.Sprintf("The instance %v has been created, but its tags couldn't be saved. Please create a ticket", result.Instance.Id)
+		finishOperation(op.ID, err, message)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: message})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, op)
+}
+
+func restartRDSInstanceHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	log.Printf("%v restarts RDS instance %v @ OTC.", username, instance.Id)
+	if err := instances.Restart(client, restartRDSInstanceOpts{}, instance.Id).Err; err != nil {
+		log.Printf("Error while restarting RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Restart initiated."})
+}
+
+func resizeRDSInstanceHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	var data RDSFlavorCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.Flavor == instance.FlavorRef {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The instance already has this flavor"})
+		return
+	}
+	ha := strings.EqualFold(instance.Type, "ha")
+	if err := validateRDSFlavor(client, getRDSInstanceEngine(*instance), instance.DataStore.Version, data.Flavor, ha); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("%v changes flavor of RDS instance %v from %v to %v @ OTC.", username, instance.Id, instance.FlavorRef, data.Flavor)
+	opts := instances.ResizeFlavorOpts{
+		ResizeFlavor: &instances.SpecCode{Speccode: data.Flavor},
+	}
+	if err := instances.Resize(client, opts, instance.Id).Err; err != nil {
+		log.Printf("Error while resizing RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Flavor change initiated. The instance will be restarted."})
+}
+
+func extendRDSInstanceHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	var data RDSVolumeCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	client, instance, err := getRDSInstanceWithPermissions(id, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.VolumeSize <= instance.Volume.Size {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("The new size must be bigger than the current size (%v GB)", instance.Volume.Size)})
+		return
+	}
+	if err := validateRDSVolumeSize(data.VolumeSize); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("%v extends storage of RDS instance %v to %v GB @ OTC.", username, instance.Id, data.VolumeSize)
+	opts := instances.EnlargeVolumeRdsOpts{
+		EnlargeVolume: &instances.EnlargeVolumeSize{Size: data.VolumeSize},
+	}
+	if err := instances.EnlargeVolume(client, opts, instance.Id).Err; err != nil {
+		log.Printf("Error while extending storage of RDS instance %v: %v", instance.Id, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Storage extension initiated."})
+}
+
+func validateNewRDSInstance(data NewRDSInstanceCommand) error {
+	if data.Name == "" {
+		return fmt.Errorf("Name must be provided")
+	}
+	if data.Billing == "" {
+		return fmt.Errorf("Billing information must be provided")
+	}
+	if data.Version == "" || data.Flavor == "" || data.VolumeType == "" {
+		return fmt.Errorf("Version, flavor and volume type must be provided")
+	}
+	if data.AvailabilityZone == "" {
+		return fmt.Errorf("Availability zone must be provided")
+	}
+	if len(data.Password) < 8 {
+		return fmt.Errorf("The password must be at least 8 characters long")
+	}
+	versionWhitelist := getRDSVersionWhitelist(data.Engine)
+	if len(versionWhitelist) > 0 && !common.ContainsStringI(versionWhitelist, data.Version) {
+		return fmt.Errorf("Invalid version: %v", data.Version)
+	}
+	return validateRDSVolumeSize(data.VolumeSize)
+}
+
+// validateRDSVolumeSize checks the size against rds.min_volume_gb and rds.max_volume_gb
+func validateRDSVolumeSize(size int) error {
+	cfg := config.Config()
+	maxSize := cfg.GetInt("rds.max_volume_gb")
+	if maxSize <= 0 {
+		log.Printf("Error: rds.max_volume_gb is not set")
+		return fmt.Errorf(common.ConfigNotSetError)
+	}
+	minSize := cfg.GetInt("rds.min_volume_gb")
+	if minSize <= 0 {
+		minSize = defaultRDSMinVolumeGB
+	}
+	if size < minSize || size > maxSize {
+		return fmt.Errorf("The size must be between %v and %v GB", minSize, maxSize)
+	}
+	if size%rdsVolumeStepGB != 0 {
+		return fmt.Errorf("The size must be a multiple of %v GB", rdsVolumeStepGB)
+	}
+	return nil
+}
+
+func validateRDSVersion(client *gophercloud.ServiceClient, engine string, version string) error {
+	allPages, err := datastores.List(client, engine).AllPages()
+	if err != nil {
+		log.Println("Error while listing datastores.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	allDatastores, err := datastores.ExtractDataStores(allPages)
+	if err != nil {
+		log.Println("Error while extracting datastores.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	for _, d := range allDatastores.DataStores {
+		if d.Name == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid version: %v", version)
+}
+
+// validateRDSFlavor checks if the flavor exists for the version and matches the HA mode
+func validateRDSFlavor(client *gophercloud.ServiceClient, engine string, version string, flavor string, ha bool) error {
+	allPages, err := flavors.List(client, flavors.DbFlavorsOpts{Versionname: version}, engine).AllPages()
+	if err != nil {
+		log.Println("Error while listing flavors.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	allFlavors, err := flavors.ExtractDbFlavors(allPages)
+	if err != nil {
+		log.Println("Error while extracting flavors.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	mode := "single"
+	if ha {
+		mode = "ha"
+	}
+	for _, f := range allFlavors.Flavorslist {
+		if f.Speccode == flavor && strings.EqualFold(f.Instancemode, mode) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid flavor: %v", flavor)
+}
+
+func validateRDSVolumeType(client *gophercloud.ServiceClient, engine string, version string, volumeType string) error {
+	allPages, err := storagetype.List(client, storagetype.ListOpts{VersionName: version}, engine).AllPages()
+	if err != nil {
+		log.Println("Error while listing storage types.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	storageTypes, err := storagetype.ExtractStorageType(allPages)
+	if err != nil {
+		log.Println("Error while extracting storage types.", err.Error())
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	for _, s := range storageTypes.StorageTypeList {
+		if s.Name == volumeType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid volume type: %v", volumeType)
+}
+
+// ValidateRDSPermissions checks if the user is a member of the rds_group of the instance.
+// It is used by other packages, e.g. to validate Ansible Tower jobs.
+func ValidateRDSPermissions(id string, username string) error {
+	if id == "" || username == "" {
+		log.Printf("Error: empty RDS instance id or username")
+		return fmt.Errorf(genericOTCAPIError)
+	}
+	_, _, err := getRDSInstanceWithPermissions(id, username)
+	return err
+}
+
+// getRDSInstanceWithPermissions returns the instance and the client of its tenant,
+// if the user is a member of the rds_group of the instance
+func getRDSInstanceWithPermissions(id string, username string) (*gophercloud.ServiceClient, *instances.RdsInstanceResponse, error) {
+	client, instance, err := getRDSInstanceByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := getGroups(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, err := getRDSInstanceTags(client, instance)
+	if err != nil {
+		return nil, nil, fmt.Errorf(genericOTCAPIError)
+	}
+	if !hasRDSAccess(tags, groups) {
+		log.Printf("%v is not a member of the rds_group of instance %v (tags: %v)", username, instance.Id, tags)
+		return nil, nil, fmt.Errorf(genericOTCAPIError)
+	}
+	return client, instance, nil
+}
+
+// getRDSInstanceByID searches the instance in all tenants
+func getRDSInstanceByID(id string) (*gophercloud.ServiceClient, *instances.RdsInstanceResponse, error) {
+	domains, err := getTenantDomains()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, domain := range domains {
+		client, err := getRDSClient(domain)
+		if err != nil {
+			return nil, nil, err
+		}
+		allPages, err := instances.List(client, instances.ListRdsInstanceOpts{Id: id}).AllPages()
+		if err != nil {
+			log.Printf("Error while listing RDS instances in %v: %v", domain, err)
+			continue
+		}
+		result, err := instances.ExtractRdsInstances(allPages)
+		if err != nil {
+			log.Printf("Error while extracting RDS instances in %v: %v", domain, err)
+			continue
+		}
+		for _, instance := range result.Instances {
+			if instance.Id == id {
+				return client, &instance, nil
+			}
+		}
+	}
+	log.Printf("Error: RDS instance %v not found", id)
+	return nil, nil, fmt.Errorf(genericOTCAPIError)
+}
+
+// getRDSInstanceTags returns the tags of the master node of the instance.
+// The tags of an instance, which is being built, are taken from the pending tags.
+func getRDSInstanceTags(client *gophercloud.ServiceClient, instance *instances.RdsInstanceResponse) (map[string]string, error) {
+	clientV1, err := getRDSV1Client(client.ProviderClient)
+	if err != nil {
+		return nil, err
+	}
+	id, err := getMasterNodeID(instance.Nodes)
+	if err != nil {
+		if pending, ok := withPendingRDSTags(instance.Id, nil); ok {
+			return pending, nil
+		}
+		log.Printf("Error while getting the ID for: %v", instance.Id)
+		return nil, err
+	}
+	tags, err := getRDSTags(clientV1, id)
+	if err != nil {
+		return nil, err
+	}
+	tags, _ = withPendingRDSTags(instance.Id, tags)
+	return tags, nil
+}
@@ -0,0 +1,52 @@
+package otc
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func TestValidateRDSVolumeSize(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("rds.max_volume_gb", 500)
+
+	var testsets = []struct {
+		size      int
+		expectErr bool
+	}{
+		{40, false},
+		{100, false},
+		{500, false},
+		{30, true},
+		{510, true},
+		{45, true},
+		{0, true},
+	}
+
+	for _, tt := range testsets {
+		err := validateRDSVolumeSize(tt.size)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! size %v: expected error: %v, got: %v", tt.size, tt.expectErr, err)
+		}
+	}
+}
+
+func TestHasRDSAccess(t *testing.T) {
+	groups := []string{"DG_GROUP_A", "DG_GROUP_B"}
+	var testsets = []struct {
+		tags     map[string]string
+		expected bool
+	}{
+		{map[string]string{"rds_group": "DG_GROUP_A"}, true},
+		{map[string]string{"rds_group": "dg_group_b"}, true},
+		{map[string]string{"rds_group": "DG_GROUP_C"}, false},
+		{map[string]string{"rds_group": ""}, false},
+		{map[string]string{}, false},
+	}
+
+	for _, tt := range testsets {
+		if hasRDSAccess(tt.tags, groups) != tt.expected {
+			t.Errorf("ERROR! tags %v: expected %v", tt.tags, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package otc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+const (
+	// Pending tags of instances, which are not found anymore, are removed after this duration
+	rdsPendingTagsMaxAge = 7 * 24 * time.Hour
+)
+
+// pendingRDSTags are the tags of a new instance. OTC only allows to tag the
+// master node, which exists when the instance is active. Until then the tags
+// are stored in rds.pending_tags_file, so that they survive a restart.
+type pendingRDSTags struct {
+	InstanceID  string            `json:"instanceId"`
+	Tags        map[string]string `json:"tags"`
+	OperationID string            `json:"operationId"`
+	Created     time.Time         `json:"created"`
+}
+
+var pendingRDSTagStore = struct {
+	sync.Mutex
+	m map[string]pendingRDSTags
+}{
+	m: make(map[string]pendingRDSTags),
+}
+
+// addPendingRDSTags stores the tags, until the instance is active and tagged by runRDSTagging
+func addPendingRDSTags(instanceID string, tags map[string]string, operationID string) error {
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+
+	pendingRDSTagStore.m[instanceID] = pendingRDSTags{
+		InstanceID:  instanceID,
+		Tags:        tags,
+		OperationID: operationID,
+		Created:     time.Now(),
+	}
+	return savePendingRDSTags()
+}
+
+func removePendingRDSTags(instanceID string) {
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+
+	delete(pendingRDSTagStore.m, instanceID)
+	if err := savePendingRDSTags(); err != nil {
+		log.Printf("Error saving pending RDS tags: %v", err)
+	}
+}
+
+// getPendingRDSTags returns the tags of an instance, which is not tagged yet
+func getPendingRDSTags(instanceID string) (map[string]string, bool) {
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+
+	p, ok := pendingRDSTagStore.m[instanceID]
+	return p.Tags, ok
+}
+
+// withPendingRDSTags adds the pending tags of the instance to its tags, so that
+// an instance isn't hidden from its rds_group while it is being built
+func withPendingRDSTags(instanceID string, tags map[string]string) (map[string]string, bool) {
+	pending, ok := getPendingRDSTags(instanceID)
+	if !ok {
+		return tags, false
+	}
+	merged := make(map[string]string)
+	for key, value := range pending {
+		merged[key] = value
+	}
+	// Tags, which are already set, have precedence
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return merged, true
+}
+
+func listPendingRDSTags() []pendingRDSTags {
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+
+	var result []pendingRDSTags
+	for _, p := range pendingRDSTagStore.m {
+		result = append(result, p)
+	}
+	return result
+}
+
+// savePendingRDSTags writes the store to rds.pending_tags_file. The caller must hold the lock.
+func savePendingRDSTags() error {
+	path := config.Config().GetString("rds.pending_tags_file")
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(pendingRDSTagStore.m)
+	if err != nil {
+		return err
+	}
+	// Replace the file at once, so that a crash doesn't leave a partial file
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// loadPendingRDSTags reads the tags, which were not set before the last restart
+func loadPendingRDSTags() error {
+	path := config.Config().GetString("rds.pending_tags_file")
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m := make(map[string]pendingRDSTags)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+	for id, p := range m {
+		pendingRDSTagStore.m[id] = p
+	}
+	return nil
+}
+
+// StartRDSTagging tags the new RDS instances as soon as they are active.
+// It runs in the background until the server stops.
+func StartRDSTagging() {
+	if !GetFeatures().RDS {
+		return
+	}
+	if config.Config().GetString("rds.pending_tags_file") == "" {
+		log.Println("WARNING: rds.pending_tags_file is not set. Tags of RDS instances, which are being created, are lost on a restart")
+	}
+	if err := loadPendingRDSTags(); err != nil {
+		log.Printf("Error loading pending RDS tags: %v", err)
+	}
+	log.Println("Starting RDS tagging")
+	go func() {
+		for now := range time.Tick(rdsBuildPollInterval) {
+			runRDSTagging(now)
+		}
+	}()
+}
+
+func runRDSTagging(now time.Time) {
+	for _, p := range listPendingRDSTags() {
+		done, err := tagPendingRDSInstance(p)
+		if done {
+			removePendingRDSTags(p.InstanceID)
+			if err != nil {
+				finishOperation(p.OperationID, err, "The instance couldn't be built. Please create a ticket")
+			} else {
+				finishOperation(p.OperationID, nil, "The instance is active and has been tagged")
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("RDS tagging: instance %v: %v. Retrying", p.InstanceID, err)
+		}
+		age := now.Sub(p.Created)
+		if age > rdsBuildTimeout {
+			// The instance stays in the store, the tags are still set when it becomes active
+			finishOperation(p.OperationID, fmt.Errorf("Timeout"), "The instance isn't tagged yet. Please create a ticket")
+		}
+		if age > rdsPendingTagsMaxAge {
+			log.Printf("Error: RDS instance %v couldn't be tagged since %v. Giving up (tags: %v)", p.InstanceID, p.Created, p.Tags)
+			removePendingRDSTags(p.InstanceID)
+		}
+	}
+}
+
+// tagPendingRDSInstance sets the tags, if the instance is active.
+// It returns true, if the instance is tagged or failed and must not be retried.
+func tagPendingRDSInstance(p pendingRDSTags) (bool, error) {
+	client, instance, err := getRDSInstanceByID(p.InstanceID)
+	if err != nil {
+		return false, err
+	}
+	switch instance.Status {
+	case "ACTIVE":
+	case "FAILED":
+		log.Printf("Error: RDS instance %v failed", p.InstanceID)
+		return true, fmt.Errorf("RDS instance %v failed", p.InstanceID)
+	default:
+		return false, nil
+	}
+
+	clientV1, err := getRDSV1Client(client.ProviderClient)
+	if err != nil {
+		return false, err
+	}
+	nodeID, err := getMasterNodeID(instance.Nodes)
+	if err != nil {
+		return false, err
+	}
+	for key, value := range p.Tags {
+		if value == "" {
+			continue
+		}
+		if err := setRDSTag(clientV1, nodeID, key, value); err != nil {
+			return false, fmt.Errorf("Error setting tag %v: %v", key, err)
+		}
+	}
+	log.Printf("RDS instance %v has been tagged: %v", p.InstanceID, p.Tags)
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package otc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func resetPendingRDSTags() {
+	pendingRDSTagStore.Lock()
+	defer pendingRDSTagStore.Unlock()
+	pendingRDSTagStore.m = make(map[string]pendingRDSTags)
+}
+
+func TestPendingRDSTagsSurviveRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rds-tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.Init("bla")
+	config.Config().Set("rds.pending_tags_file", filepath.Join(dir, "pending.json"))
+	resetPendingRDSTags()
+
+	tags := map[string]string{"rds_group": "DG_DB", "billing": "123", "creator": "u123"}
+	if err := addPendingRDSTags("i-1", tags, "op-1"); err != nil {
+		t.Fatalf("ERROR! Saving the tags failed: %v", err)
+	}
+	if err := addPendingRDSTags("i-2", tags, "op-2"); err != nil {
+		t.Fatalf("ERROR! Saving the tags failed: %v", err)
+	}
+	removePendingRDSTags("i-2")
+
+	// Restart
+	resetPendingRDSTags()
+	if err := loadPendingRDSTags(); err != nil {
+		t.Fatalf("ERROR! Loading the tags failed: %v", err)
+	}
+	pending := listPendingRDSTags()
+	if len(pending) != 1 || pending[0].InstanceID != "i-1" || pending[0].OperationID != "op-1" || pending[0].Tags["rds_group"] != "DG_DB" {
+		t.Errorf("ERROR! Expected the tags of i-1, got %+v", pending)
+	}
+	resetPendingRDSTags()
+}
+
+func TestWithPendingRDSTags(t *testing.T) {
+	config.Init("bla")
+	resetPendingRDSTags()
+	defer resetPendingRDSTags()
+	addPendingRDSTags("i-1", map[string]string{"rds_group": "DG_DB", "billing": "123"}, "op-1")
+
+	tags, pending := withPendingRDSTags("i-1", map[string]string{"billing": "456"})
+	if !pending || tags["rds_group"] != "DG_DB" || tags["billing"] != "456" {
+		t.Errorf("ERROR! Expected the pending rds_group and the set billing, got %v", tags)
+	}
+	if !hasRDSAccess(tags, []string{"dg_db"}) {
+		t.Errorf("ERROR! The rds_group must have access to a pending instance")
+	}
+	tags, pending = withPendingRDSTags("i-2", map[string]string{"billing": "456"})
+	if pending || tags["rds_group"] != "" {
+		t.Errorf("ERROR! Expected no pending tags, got %v", tags)
+	}
+}
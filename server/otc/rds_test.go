@@ -0,0 +1,62 @@
+package otc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gophercloud/gophercloud"
+)
+
+func TestLookupRDSTags(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("rds.tag_workers", 2)
+
+	lookup := func(id string) (map[string]string, error) {
+		switch id {
+		case "slow":
+			time.Sleep(time.Second)
+		case "broken":
+			return nil, fmt.Errorf("error")
+		}
+		return map[string]string{"rds_group": id}, nil
+	}
+
+	allTags, missing := lookupRDSTags([]string{"a", "b", "c", "broken"}, time.Now().Add(time.Second), lookup)
+	if len(allTags) != 3 || allTags["c"]["rds_group"] != "c" {
+		t.Errorf("ERROR! unexpected tags: %v", allTags)
+	}
+	if missing != 1 {
+		t.Errorf("ERROR! expected 1 missing, got %v", missing)
+	}
+
+	config.Config().Set("rds.tag_workers", 3)
+	start := time.Now()
+	allTags, missing = lookupRDSTags([]string{"slow", "slow", "a"}, time.Now().Add(100*time.Millisecond), lookup)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("ERROR! lookup didn't stop at the deadline")
+	}
+	if missing != 2 || allTags["a"] == nil {
+		t.Errorf("ERROR! expected partial result, got %v (%v missing)", allTags, missing)
+	}
+
+	allTags, missing = lookupRDSTags(nil, time.Now().Add(time.Second), lookup)
+	if len(allTags) != 0 || missing != 0 {
+		t.Errorf("ERROR! expected empty result, got %v (%v missing)", allTags, missing)
+	}
+}
+
+func TestGetRDSTagsURL(t *testing.T) {
+	client := &gophercloud.ServiceClient{Endpoint: "https://rds.eu-ch.o13bb.otc.t-systems.com/rds/v1/1234/"}
+	expected := "https://rds.eu-ch.o13bb.otc.t-systems.com/v1/1234/rds/node1/tags"
+	for i := 0; i < 2; i++ {
+		if url := getRDSTagsURL(client, "node1"); url != expected {
+			t.Errorf("ERROR! Expected %v, got %v", expected, url)
+		}
+	}
+	// The client is shared by the workers and must not be changed
+	if client.Endpoint != "https://rds.eu-ch.o13bb.otc.t-systems.com/rds/v1/1234/" {
+		t.Errorf("ERROR! The endpoint of the client has been changed: %v", client.Endpoint)
+	}
+}
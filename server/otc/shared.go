@@ -3,6 +3,7 @@ package otc
 import (
 	"errors"
 	"fmt"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
 	"github.com/gin-gonic/gin"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/auth/token"
@@ -17,14 +18,33 @@ const (
 
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/otc/ecs", listECSHandler)
+	r.POST("/otc/ecs", createECSHandler)
+	r.DELETE("/otc/ecs/:id", deleteECSHandler)
+	r.DELETE("/otc/ecs/:id/deletion", cancelECSDeletionHandler)
+	r.GET("/otc/ecs/:id/volumes", listECSVolumesHandler)
+	r.POST("/otc/ecs/:id/volumes/:volumeid", attachVolumeHandler)
+	r.DELETE("/otc/ecs/:id/volumes/:volumeid", detachVolumeHandler)
+	r.POST("/otc/volumes", createVolumeHandler)
+	r.POST("/otc/volumes/:volumeid/extend", extendVolumeHandler)
+	r.GET("/otc/operations/:id", getOperationHandler)
+	r.GET("/otc/cache/status", getCacheStatusHandler)
 	r.POST("/otc/stopecs", stopECSHandler)
 	r.POST("/otc/startecs", startECSHandler)
 	r.POST("/otc/rebootecs", rebootECSHandler)
 	r.GET("/otc/flavors", listFlavorsHandler)
 	r.GET("/otc/images", listImagesHandler)
+	r.GET("/otc/rds/engines", listRDSEnginesHandler)
 	r.GET("/otc/rds/versions", listRDSVersionsHandler)
 	r.GET("/otc/rds/flavors", listRDSFlavorsHandler)
 	r.GET("/otc/rds/instances", listRDSInstancesHandler)
+	r.POST("/otc/rds/instances", createRDSInstanceHandler)
+	r.POST("/otc/rds/instances/:id/restart", restartRDSInstanceHandler)
+	r.POST("/otc/rds/instances/:id/resize", resizeRDSInstanceHandler)
+	r.POST("/otc/rds/instances/:id/extend", extendRDSInstanceHandler)
+	r.GET("/otc/rds/instances/:id/backups", listRDSBackupsHandler)
+	r.POST("/otc/rds/instances/:id/backups", createRDSBackupHandler)
+	r.PUT("/otc/rds/instances/:id/backups/policy", updateRDSBackupPolicyHandler)
+	r.POST("/otc/rds/instances/:id/restore", restoreRDSInstanceHandler)
 }
 
 func getProvider(to *token.TokenOptions) (*gophercloud.ProviderClient, error) {
@@ -41,9 +61,13 @@ func getProvider(to *token.TokenOptions) (*gophercloud.ProviderClient, error) {
 }
 
 func getComputeClient(domain string) (*gophercloud.ServiceClient, error) {
+	t, err := getTenant(domain)
+	if err != nil {
+		return nil, err
+	}
 	to := token.TokenOptions{
-		TenantName: "eu-ch_managed",
-		DomainName: domain,
+		TenantName: t.Project,
+		DomainName: t.Domain,
 	}
 	provider, err := getProvider(&to)
 	if err != nil {
@@ -52,7 +76,7 @@ func getComputeClient(domain string) (*gophercloud.ServiceClient, error) {
 	}
 
 	client, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{
-		Region: "eu-ch",
+		Region: t.Region,
 	})
 
 	if err != nil {
@@ -74,9 +98,17 @@ func getRDSV1Client(provider *gophercloud.ProviderClient) (*gophercloud.ServiceC
 }
 
 func getRDSClient(domain string) (*gophercloud.ServiceClient, error) {
+	t, err := getTenant(domain)
+	if err != nil {
+		return nil, err
+	}
+	if t.RDSProject == "" {
+		fmt.Println("Error: rds_project is not set for tenant", t.Domain)
+		return nil, errors.New(common.ConfigNotSetError)
+	}
 	to := token.TokenOptions{
-		TenantName: "eu-ch_rds",
-		DomainName: domain,
+		TenantName: t.RDSProject,
+		DomainName: t.Domain,
 	}
 	provider, err := getProvider(&to)
 	if err != nil {
@@ -84,7 +116,9 @@ func getRDSClient(domain string) (*gophercloud.ServiceClient, error) {
 		return nil, errors.New(genericOTCAPIError)
 	}
 
-	client, err := openstack.NewRDSV3(provider, gophercloud.EndpointOpts{})
+	client, err := openstack.NewRDSV3(provider, gophercloud.EndpointOpts{
+		Region: t.Region,
+	})
 	if err != nil {
 		fmt.Println("Error getting client.", err.Error())
 		return nil, errors.New(genericOTCAPIError)
@@ -93,15 +127,23 @@ func getRDSClient(domain string) (*gophercloud.ServiceClient, error) {
 	return client, nil
 }
 
-func getImageClient() (*gophercloud.ServiceClient, error) {
-	provider, err := getProvider(nil)
+func getImageClient(domain string) (*gophercloud.ServiceClient, error) {
+	t, err := getTenant(domain)
+	if err != nil {
+		return nil, err
+	}
+	to := token.TokenOptions{
+		TenantName: t.Project,
+		DomainName: t.Domain,
+	}
+	provider, err := getProvider(&to)
 	if err != nil {
 		fmt.Println("Error while authenticating.", err.Error())
 		return nil, errors.New(genericOTCAPIError)
 	}
 
 	client, err := openstack.NewImageServiceV2(provider, gophercloud.EndpointOpts{
-		Region: "eu-ch",
+		Region: t.Region,
 	})
 
 	if err != nil {
@@ -112,15 +154,23 @@ func getImageClient() (*gophercloud.ServiceClient, error) {
 	return client, nil
 }
 
-func getBlockStorageClient() (*gophercloud.ServiceClient, error) {
-	provider, err := getProvider(nil)
+func getBlockStorageClient(domain string) (*gophercloud.ServiceClient, error) {
+	t, err := getTenant(domain)
+	if err != nil {
+		return nil, err
+	}
+	to := token.TokenOptions{
+		TenantName: t.Project,
+		DomainName: t.Domain,
+	}
+	provider, err := getProvider(&to)
 	if err != nil {
 		fmt.Println("Error while authenticating.", err.Error())
 		return nil, errors.New(genericOTCAPIError)
 	}
 
 	client, err := openstack.NewBlockStorageV3(provider, gophercloud.EndpointOpts{
-		Region: "eu-ch",
+		Region: t.Region,
 	})
 
 	if err != nil {
@@ -0,0 +1,135 @@
+package otc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// tenant is an OTC domain with its projects (otc.tenants)
+type tenant struct {
+	Domain     string `mapstructure:"domain"`
+	Project    string `mapstructure:"project"`
+	RDSProject string `mapstructure:"rds_project"`
+	Region     string `mapstructure:"region"`
+	// Used by the frontend to select the tenant, e.g. t or p
+	Stage string `mapstructure:"stage"`
+	// Servers with a matching name belong to this tenant
+	HostnamePattern string `mapstructure:"hostname_pattern"`
+	hostnameRegexp  *regexp.Regexp
+	// Network of new servers
+	NetworkID string `mapstructure:"network_id"`
+	// Network of new RDS instances
+	RDSVPCID           string `mapstructure:"rds_vpc_id"`
+	RDSSubnetID        string `mapstructure:"rds_subnet_id"`
+	RDSSecurityGroupID string `mapstructure:"rds_security_group_id"`
+}
+
+// tenantsCache contains the tenants of the loaded config. They are parsed once per config.
+var tenantsCache struct {
+	sync.Mutex
+	config  *viper.Viper
+	tenants []tenant
+	err     error
+}
+
+func getTenants() ([]tenant, error) {
+	tenantsCache.Lock()
+	defer tenantsCache.Unlock()
+
+	if tenantsCache.config != config.Config() {
+		tenantsCache.tenants, tenantsCache.err = parseTenants()
+		tenantsCache.config = config.Config()
+	}
+	return tenantsCache.tenants, tenantsCache.err
+}
+
+func parseTenants() ([]tenant, error) {
+	tenants := []tenant{}
+	if err := config.Config().UnmarshalKey("otc.tenants", &tenants); err != nil {
+		log.Printf("Error getting tenants: %v", err)
+		return nil, fmt.Errorf(common.ConfigNotSetError)
+	}
+	if len(tenants) == 0 {
+		log.Printf("Error: no tenants found in config (otc.tenants)")
+		return nil, fmt.Errorf(common.ConfigNotSetError)
+	}
+	for i, t := range tenants {
+		if t.Domain == "" || t.Project == "" || t.Region == "" || t.Stage == "" || t.HostnamePattern == "" {
+			log.Printf("Error: missing domain, project, region, stage or hostname_pattern in tenant: %+v", t)
+			return nil, fmt.Errorf(common.ConfigNotSetError)
+		}
+		hostnameRegexp, err := regexp.Compile(t.HostnamePattern)
+		if err != nil {
+			log.Printf("Error: invalid hostname_pattern in tenant %v: %v", t.Domain, err)
+			return nil, fmt.Errorf(common.ConfigNotSetError)
+		}
+		tenants[i].hostnameRegexp = hostnameRegexp
+	}
+	return tenants, nil
+}
+
+func getTenant(domain string) (*tenant, error) {
+	tenants, err := getTenants()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		if t.Domain == domain {
+			return &t, nil
+		}
+	}
+	log.Printf("Error: tenant %v not found in config (otc.tenants)", domain)
+	return nil, fmt.Errorf(genericOTCAPIError)
+}
+
+// getTenantByStage returns the tenant with the stage alias
+func getTenantByStage(stage string) (*tenant, error) {
+	if stage == "" {
+		return nil, fmt.Errorf("Wrong API usage. Missing parameter stage")
+	}
+	tenants, err := getTenants()
+	if err != nil {
+		return nil, err
+	}
+	var stages []string
+	for _, t := range tenants {
+		if strings.EqualFold(t.Stage, stage) {
+			return &t, nil
+		}
+		stages = append(stages, t.Stage)
+	}
+	return nil, fmt.Errorf("Wrong API usage. Parameter stage is: %v. Should be one of: %v", stage, strings.Join(stages, ", "))
+}
+
+// getTenantName returns the domain of the first tenant, whose hostname pattern matches the servername
+func getTenantName(servername string) string {
+	tenants, err := getTenants()
+	if err != nil {
+		return ""
+	}
+	for _, t := range tenants {
+		if t.hostnameRegexp.MatchString(servername) {
+			return t.Domain
+		}
+	}
+	return ""
+}
+
+func getTenantDomains() ([]string, error) {
+	tenants, err := getTenants()
+	if err != nil {
+		return nil, err
+	}
+	var domains []string
+	for _, t := range tenants {
+		domains = append(domains, t.Domain)
+	}
+	return domains, nil
+}
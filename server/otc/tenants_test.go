@@ -0,0 +1,95 @@
+package otc
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func setTestTenants() {
+	config.Init("bla")
+	config.Config().Set("otc.tenants", []map[string]string{
+		{
+			"domain":           "SBB_RZ_T_001",
+			"project":          "eu-ch_managed",
+			"region":           "eu-ch",
+			"stage":            "t",
+			"hostname_pattern": `t\d{2}\.sbb\.ch$`,
+		},
+		{
+			"domain":           "SBB_RZ_P_001",
+			"project":          "eu-ch_managed",
+			"region":           "eu-ch",
+			"stage":            "p",
+			"hostname_pattern": `p\d{2}\.sbb\.ch$`,
+		},
+	})
+}
+
+func TestGetTenantName(t *testing.T) {
+	setTestTenants()
+
+	var testsets = []struct {
+		servername string
+		expected   string
+	}{
+		{"xyzt01.sbb.ch", "SBB_RZ_T_001"},
+		{"xyzp99.sbb.ch", "SBB_RZ_P_001"},
+		{"xyzx01.sbb.ch", ""},
+		{"xyzt1.sbb.ch", ""},
+		{"xyzt01.sbb.ch.example.com", ""},
+		{"", ""},
+	}
+
+	for _, tt := range testsets {
+		tenant := getTenantName(tt.servername)
+		if tenant != tt.expected {
+			t.Errorf("ERROR! %v: expected %v, got %v", tt.servername, tt.expected, tenant)
+		}
+	}
+}
+
+func TestGetTenantByStage(t *testing.T) {
+	setTestTenants()
+
+	var testsets = []struct {
+		stage     string
+		expected  string
+		expectErr bool
+	}{
+		{"t", "SBB_RZ_T_001", false},
+		{"P", "SBB_RZ_P_001", false},
+		{"x", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range testsets {
+		tenant, err := getTenantByStage(tt.stage)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %v: expected error: %v, got: %v", tt.stage, tt.expectErr, err)
+			continue
+		}
+		if err == nil && tenant.Domain != tt.expected {
+			t.Errorf("ERROR! %v: expected %v, got %v", tt.stage, tt.expected, tenant.Domain)
+		}
+	}
+}
+
+func TestGetTenantsParsedOncePerConfig(t *testing.T) {
+	setTestTenants()
+
+	tenants, err := getTenants()
+	if err != nil || len(tenants) != 2 {
+		t.Fatalf("ERROR! Expected 2 tenants, got %v (%v)", len(tenants), err)
+	}
+	// Changes of the same config are not parsed again
+	config.Config().Set("otc.tenants", []map[string]string{})
+	if tenants, err := getTenants(); err != nil || len(tenants) != 2 {
+		t.Errorf("ERROR! Expected the cached tenants, got %v (%v)", len(tenants), err)
+	}
+	// A new config is parsed
+	config.Init("bla")
+	if _, err := getTenants(); err == nil {
+		t.Errorf("ERROR! Expected an error without tenants")
+	}
+}
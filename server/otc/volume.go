@@ -0,0 +1,341 @@
+package otc
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/extensions/volumeactions"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Used if uos.min_volume_gb is not set. OTC doesn't allow smaller EVS disks.
+	defaultMinVolumeGB = 10
+)
+
+func listECSVolumesHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+
+	if err := validatePermissions([]servers.Server{{ID: id}}, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	_, server, err := getServerByID(id, username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	client, err := getBlockStorageClient(getTenantName(server.Name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	attachedVolumes, err := getVolumesByServerID(client, server.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if attachedVolumes == nil {
+		attachedVolumes = []volumes.Volume{}
+	}
+	c.JSON(http.StatusOK, VolumeListResponse{Volumes: attachedVolumes})
+}
+
+func createVolumeHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	var data NewVolumeCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+	log.WithFields(log.Fields{
+		"username": username,
+		"data":     data,
+	}).Info("Creating volume @ OTC.")
+
+	if err := validateUOSGroup(data.UOSGroup, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	t, err := getTenantByStage(data.Stage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.Name == "" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Volume name must be provided"})
+		return
+	}
+	if err := validateVolumeSize(data.DiskSize); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	client, err := getBlockStorageClient(t.Domain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	volumeTypes, err := getVolumeTypes(client)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if !containsVolumeType(volumeTypes.VolumeTypes, data.VolumeTypeId) {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Invalid volume type: %v", data.VolumeTypeId)})
+		return
+	}
+
+	opts := volumes.CreateOpts{
+		Name:             data.Name,
+		Size:             data.DiskSize,
+		VolumeType:       data.VolumeTypeId,
+		AvailabilityZone: data.AvailabilityZone,
+		Metadata: map[string]string{
+			"uos_group":   data.UOSGroup,
+			"uos_creator": username,
+		},
+	}
+	volume, err := volumes.Create(client, opts).Extract()
+	if err != nil {
+		log.Printf("Error while creating volume: %v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, volume)
+}
+
+func attachVolumeHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+	volumeID := c.Param("volumeid")
+
+	computeClient, server, client, volume, err := getServerAndVolume(id, volumeID, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if volume.Status != "available" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The volume is not available"})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+		"server":   server.ID,
+		"volume":   volume.ID,
+	}).Info("Attaching volume @ OTC.")
+	if _, err := volumeattach.Create(computeClient, server.ID, volumeattach.CreateOpts{VolumeID: volume.ID}).Extract(); err != nil {
+		log.Printf("Error while attaching volume %v: %v", volume.ID, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if err := waitForVolumeStatus(client, volume.ID, "in-use", volumeStatusTimeout); err != nil {
+		log.Printf("Error while waiting for volume %v: %v", volume.ID, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The volume couldn't be attached. Please create a ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "The volume has been attached"})
+}
+
+func detachVolumeHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	id := c.Param("id")
+	volumeID := c.Param("volumeid")
+
+	computeClient, server, client, volume, err := getServerAndVolume(id, volumeID, username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if !isAttachedTo(volume, server.ID) {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The volume is not attached to this server"})
+		return
+	}
+	if volume.Bootable == "true" {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The root volume can't be detached"})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+		"server":   server.ID,
+		"volume":   volume.ID,
+	}).Info("Detaching volume @ OTC.")
+	if err := volumeattach.Delete(computeClient, server.ID, volume.ID).ExtractErr(); err != nil {
+		log.Printf("Error while detaching volume %v: %v", volume.ID, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	if err := waitForVolumeStatus(client, volume.ID, "available", volumeStatusTimeout); err != nil {
+		log.Printf("Error while waiting for volume %v: %v", volume.ID, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "The volume couldn't be detached. Please create a ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "The volume has been detached"})
+}
+
+func extendVolumeHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	volumeID := c.Param("volumeid")
+
+	var data ExtendVolumeCommand
+	if err := c.BindJSON(&data); err != nil {
+		log.Println("Binding request to Go struct failed.", err.Error())
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	client, volume, err := getVolumeByID(volumeID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if err := validateVolumePermissions(volume, username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	if data.DiskSize <= volume.Size {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("The new size must be bigger than the current size (%v GB)", volume.Size)})
+		return
+	}
+	if err := validateVolumeSize(data.DiskSize); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+		"volume":   volume.ID,
+		"size":     data.DiskSize,
+	}).Info("Extending volume @ OTC.")
+	if err := volumeactions.ExtendSize(client, volume.ID, volumeactions.ExtendSizeOpts{NewSize: data.DiskSize}).ExtractErr(); err != nil {
+		log.Printf("Error while extending volume %v: %v", volume.ID, err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericOTCAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "The volume has been extended. The filesystem must be extended on the server"})
+}
+
+// validateVolumeSize checks the size against uos.min_volume_gb and uos.max_volume_gb
+func validateVolumeSize(size int) error {
+	cfg := config.Config()
+	maxSize := cfg.GetInt("uos.max_volume_gb")
+	if maxSize <= 0 {
+		log.Printf("Error: uos.max_volume_gb is not set")
+		return fmt.Errorf(common.ConfigNotSetError)
+	}
+	minSize := cfg.GetInt("uos.min_volume_gb")
+	if minSize <= 0 {
+		minSize = defaultMinVolumeGB
+	}
+	if size < minSize || size > maxSize {
+		return fmt.Errorf("The size must be between %v and %v GB", minSize, maxSize)
+	}
+	return nil
+}
+
+// getServerAndVolume checks the permissions for the server and the volume
+// and that both are in the same tenant
+func getServerAndVolume(serverID string, volumeID string, username string) (*gophercloud.ServiceClient, *servers.Server, *gophercloud.ServiceClient, *volumes.Volume, error) {
+	if err := validatePermissions([]servers.Server{{ID: serverID}}, username); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	computeClient, server, err := getServerByID(serverID, username)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	client, err := getBlockStorageClient(getTenantName(server.Name))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	volume, err := volumes.Get(client, volumeID).Extract()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"username": username,
+			"server":   serverID,
+			"volume":   volumeID,
+			"err":      err.Error(),
+		}).Error("Volume not found in the tenant of the server")
+		return nil, nil, nil, nil, fmt.Errorf(genericOTCAPIError)
+	}
+	if err := validateVolumePermissions(volume, username); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return computeClient, server, client, volume, nil
+}
+
+// getVolumeByID searches the volume in all tenants
+func getVolumeByID(id string) (*gophercloud.ServiceClient, *volumes.Volume, error) {
+	tenants, err := getTenantDomains()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tenant := range tenants {
+		client, err := getBlockStorageClient(tenant)
+		if err != nil {
+			return nil, nil, err
+		}
+		volume, err := volumes.Get(client, id).Extract()
+		if err == nil {
+			return client, volume, nil
+		}
+	}
+	log.Printf("Error: volume %v not found", id)
+	return nil, nil, fmt.Errorf(genericOTCAPIError)
+}
+
+// validateVolumePermissions checks the uos_group of the volume.
+// Volumes without uos_group (e.g. created with the server) inherit the
+// permissions of the server they are attached to.
+func validateVolumePermissions(volume *volumes.Volume, username string) error {
+	group := volume.Metadata["uos_group"]
+	if group == "" {
+		if len(volume.Attachments) == 0 {
+			log.WithFields(log.Fields{
+				"username": username,
+				"volume":   volume.ID,
+				"metadata": volume.Metadata,
+			}).Error("uos_group not found in metadata")
+			return fmt.Errorf(genericOTCAPIError)
+		}
+		var attachedServers []servers.Server
+		for _, a := range volume.Attachments {
+			attachedServers = append(attachedServers, servers.Server{ID: a.ServerID})
+		}
+		return validatePermissions(attachedServers, username)
+	}
+
+	groups, err := getGroups(username)
+	if err != nil {
+		return err
+	}
+	if common.ContainsStringI(groups, "DG_RBT_UOS_ADMINS") || common.ContainsStringI(groups, group) {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"username": username,
+		"groups":   groups,
+		"volume":   volume.ID,
+		"metadata": volume.Metadata,
+	}).Error("uos_group not found in user groups")
+	return fmt.Errorf(genericOTCAPIError)
+}
+
+func isAttachedTo(volume *volumes.Volume, serverID string) bool {
+	for _, a := range volume.Attachments {
+		if a.ServerID == serverID {
+			return true
+		}
+	}
+	return false
+}
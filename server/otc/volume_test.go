@@ -0,0 +1,33 @@
+package otc
+
+import (
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func TestValidateVolumeSize(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("uos.max_volume_gb", 500)
+
+	var testsets = []struct {
+		minSize   int
+		size      int
+		expectErr bool
+	}{
+		{0, 5, true},
+		{0, 10, false},
+		{0, 500, false},
+		{0, 501, true},
+		{50, 20, true},
+		{50, 50, false},
+	}
+
+	for _, tt := range testsets {
+		config.Config().Set("uos.min_volume_gb", tt.minSize)
+		err := validateVolumeSize(tt.size)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! min %v, size %v: expected error: %v, got: %v", tt.minSize, tt.size, tt.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sematext
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func getChargebackHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	if err := validateChargebackPermissions(username); err != nil {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	if chargeback, err := getChargeback(); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, chargeback)
+	}
+}
+
+// validateChargebackPermissions checks if the user is a member of sematext_chargeback_group
+func validateChargebackPermissions(username string) error {
+	group := config.Config().GetString("sematext_chargeback_group")
+	if group == "" {
+		return errors.New("The chargeback is not configured (sematext_chargeback_group)")
+	}
+
+	groups, err := ldap.GetGroups(username)
+	if err != nil {
+		log.Println("Error getting ldap groups of " + username + ": " + err.Error())
+		return errors.New(genericAPIError)
+	}
+
+	if !common.ContainsStringI(groups, group) {
+		return errors.New("You don't have permissions for the Sematext chargeback!")
+	}
+	return nil
+}
+
+// getChargeback returns the monthly costs of all apps per accounting number.
+// The accounting number and project are stored in the description of the app (see updateLogseneBilling).
+func getChargeback() ([]common.SematextChargeback, error) {
+	appData, err := getAllLogseneApps()
+	if err != nil {
+		return nil, err
+	}
+
+	allApps, err := appData.Path("data.apps").Children()
+	if err != nil {
+		log.Println("error getting data inside json", err.Error())
+		return nil, errors.New(genericAPIError)
+	}
+
+	byBilling := map[string]*common.SematextChargeback{}
+	for _, app := range allApps {
+		appType, _ := app.Path("appType").Data().(string)
+		if _, ok := sematextAppTypes[appType]; !ok {
+			continue
+		}
+
+		description, _ := app.Path("description").Data().(string)
+		billing, project := parseBillingInfo(description)
+
+		key := billing + "/" + project
+		chargeback, ok := byBilling[key]
+		if !ok {
+			chargeback = &common.SematextChargeback{Billing: billing, Project: project, Apps: []string{}}
+			byBilling[key] = chargeback
+		}
+
+		name, _ := app.Path("name").Data().(string)
+		pricePerDay, _ := app.Path("plan.pricePerDay").Data().(float64)
+		chargeback.Apps = append(chargeback.Apps, name)
+		chargeback.PricePerMonth = round(chargeback.PricePerMonth+30*pricePerDay, 0.05)
+	}
+
+	chargebacks := []common.SematextChargeback{}
+	for _, c := range byBilling {
+		chargebacks = append(chargebacks, *c)
+	}
+	// map order is random
+	sort.Slice(chargebacks, func(i, j int) bool {
+		if chargebacks[i].Billing != chargebacks[j].Billing {
+			return chargebacks[i].Billing < chargebacks[j].Billing
+		}
+		return chargebacks[i].Project < chargebacks[j].Project
+	})
+
+	return chargebacks, nil
+}
+
+// parseBillingInfo splits the description "<billing> / <project>"
+func parseBillingInfo(description string) (string, string) {
+	parts := strings.SplitN(description, " / ", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(description), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
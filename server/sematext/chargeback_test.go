@@ -0,0 +1,51 @@
+package sematext
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+)
+
+func TestGetChargeback(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": `{"data": {"apps": [
+		{"id": 1, "name": "app1", "appType": "Logsene", "description": "1234 / project", "plan": {"pricePerDay": 1.0}},
+		{"id": 2, "name": "app2", "appType": "Monitoring", "description": "1234 / project", "plan": {"pricePerDay": 0.5}},
+		{"id": 3, "name": "app3", "appType": "Logsene", "description": "5678 / other", "plan": {"pricePerDay": 2.0}},
+		{"id": 4, "name": "app4", "appType": "Logsene", "plan": {"pricePerDay": 0.0}},
+		{"id": 5, "name": "app5", "appType": "Experience", "description": "1234 / project", "plan": {"pricePerDay": 1.0}}
+	]}}`})
+	defer sematext.Close()
+
+	chargeback, err := getChargeback()
+	if err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	expected := []common.SematextChargeback{
+		{Billing: "", Project: "", Apps: []string{"app4"}, PricePerMonth: 0},
+		{Billing: "1234", Project: "project", Apps: []string{"app1", "app2"}, PricePerMonth: 45},
+		{Billing: "5678", Project: "other", Apps: []string{"app3"}, PricePerMonth: 60},
+	}
+	if !reflect.DeepEqual(chargeback, expected) {
+		t.Errorf("ERROR! expected %+v, got %+v", expected, chargeback)
+	}
+}
+
+func TestParseBillingInfo(t *testing.T) {
+	var testsets = []struct {
+		description     string
+		expectedBilling string
+		expectedProject string
+	}{
+		{"1234 / project", "1234", "project"},
+		{"1234 / project / sub", "1234", "project / sub"},
+		{"1234", "1234", ""},
+		{"", "", ""},
+	}
+	for _, tt := range testsets {
+		billing, project := parseBillingInfo(tt.description)
+		if billing != tt.expectedBilling || project != tt.expectedProject {
+			t.Errorf("ERROR! %v: expected %v/%v, got %v/%v", tt.description, tt.expectedBilling, tt.expectedProject, billing, project)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -19,6 +20,8 @@ const (
 	genericAPIError    = "Error when calling the Sematext API. Please open a Jira issue"
 	sematextRoleActive = "ACTIVE"
 	sematextRoleAdmin  = "ADMIN"
+	sematextRoleOwner  = "OWNER"
+	sematextRoleUser   = "USER"
 	noAccessError      = "You don't have permissions for this Sematext App!"
 )
 
@@ -28,7 +31,25 @@ func getLogseneAppsHandler(c *gin.Context) {
 
 	fmt.Sprintf("User %v listed all his sematext logsene apps", username)
 
-	if appList, err := getAllLogseneAppsForUser(mail); err != nil {
+	if appList, err := getAllAppsForUser(mail, sematextAppTypeLogsene); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, appList)
+	}
+}
+
+func getAppsHandler(c *gin.Context) {
+	mail := common.GetUserMail(c)
+	appType := c.Query("appType")
+
+	if appType != "" {
+		if err := validateAppType(appType); err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+	}
+
+	if appList, err := getAllAppsForUser(mail, appType); err != nil {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 	} else {
 		c.JSON(http.StatusOK, appList)
@@ -40,7 +61,13 @@ func getLogseneDiscountcodeHandler(c *gin.Context) {
 }
 
 func getLogsenePlansHandler(c *gin.Context) {
-	if plans, err := getAllLogsenePlans(); err != nil {
+	appType := c.DefaultQuery("appType", sematextAppTypeLogsene)
+	if err := validateAppType(appType); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	if plans, err := getAllPlans(appType); err != nil {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 	} else {
 		c.JSON(http.StatusOK, plans)
@@ -59,12 +86,13 @@ func updateLogsenePlanAndLimitHandler(c *gin.Context) {
 
 	var data common.EditSematextPlanCommand
 	if c.BindJSON(&data) == nil {
-		if err := validateLogsenePlanAndLimitEdit(mail, appId, data.PlanId, data.Limit); err != nil {
+		appType, err := validateLogsenePlanAndLimitEdit(mail, appId, data.PlanId, data.Limit)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 			return
 		}
 
-		if err := updateLogsenePlanAndLimit(username, data.PlanId, data.Limit, appId); err != nil {
+		if err := updateLogsenePlanAndLimit(username, appType, data.PlanId, data.Limit, appId); err != nil {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		} else {
 			c.JSON(http.StatusOK, common.ApiResponse{
@@ -106,12 +134,25 @@ func updateLogseneBillingHandler(c *gin.Context) {
 }
 
 func createLogseneAppHandler(c *gin.Context) {
+	createSematextAppHandler(c, sematextAppTypeLogsene)
+}
+
+func createAppHandler(c *gin.Context) {
+	createSematextAppHandler(c, "")
+}
+
+// createSematextAppHandler creates an app of the type in the request. If it is
+// not set, defaultAppType is used.
+func createSematextAppHandler(c *gin.Context, defaultAppType string) {
 	username := common.GetUserName(c)
 	mail := common.GetUserMail(c)
 
 	var data common.CreateLogseneAppCommand
 	if c.BindJSON(&data) == nil {
-		if err := validateNewLogseneApp(data.AppName, data.PlanId, data.Limit, data.Project, data.Billing); err != nil {
+		if data.AppType == "" {
+			data.AppType = defaultAppType
+		}
+		if err := validateNewLogseneApp(data.AppName, data.AppType, data.PlanId, data.Limit, data.Project, data.Billing); err != nil {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 			return
 		}
@@ -120,7 +161,7 @@ func createLogseneAppHandler(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
 		} else {
 			c.JSON(http.StatusOK, common.ApiResponse{
-				Message: fmt.Sprintf("Logsene App (%v) has been created. %v has been invited as administrator.", data.AppName, mail),
+				Message: fmt.Sprintf("%v App (%v) has been created. %v has been invited as administrator.", data.AppType, data.AppName, mail),
 			})
 		}
 	} else {
@@ -128,16 +169,21 @@ func createLogseneAppHandler(c *gin.Context) {
 	}
 }
 
-func validateNewLogseneApp(appName string, planId int, limit int, project string, billing string) error {
+func validateNewLogseneApp(appName string, appType string, planId int, limit int, project string, billing string) error {
 	if len(appName) == 0 {
 		return errors.New("App name must be provided!")
 	}
 
+	if err := validateAppType(appType); err != nil {
+		return err
+	}
+
 	if planId <= 0 {
 		return errors.New("Plan must be provided!")
 	}
 
-	if limit <= 0 {
+	// Only Logsene apps have a daily limit
+	if appType == sematextAppTypeLogsene && limit <= 0 {
 		return errors.New("Daily limit must be defined!")
 	}
 
@@ -154,7 +200,7 @@ func validateNewLogseneApp(appName string, planId int, limit int, project string
 
 func validateLogseneBillingEdit(mail string, appId int, project string, billing string) error {
 	// Check permissions
-	err := validateLogseneAppPermissions(mail, appId)
+	err := validateLogseneAppPermissions(mail, appId, "")
 	if err != nil {
 		return err
 	}
@@ -171,42 +217,68 @@ func validateLogseneBillingEdit(mail string, appId int, project string, billing
 	return nil
 }
 
-func validateLogsenePlanAndLimitEdit(mail string, appId int, planId int, limit int) error {
+// validateLogsenePlanAndLimitEdit returns the type of the app, if the user can change
+// the plan and the plan is one of the app type
+func validateLogsenePlanAndLimitEdit(mail string, appId int, planId int, limit int) (string, error) {
 	// Check permissions
-	err := validateLogseneAppPermissions(mail, appId)
+	app, err := getAppForUser(mail, appId, "")
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Check values
 	if planId <= 0 {
-		return errors.New("New plan must be provided")
+		return "", errors.New("New plan must be provided")
 	}
 
-	if limit <= 0 {
-		return errors.New("Daily-limit has to be provided")
+	// Only Logsene apps have a daily limit
+	if app.AppType == sematextAppTypeLogsene && limit <= 0 {
+		return "", errors.New("Daily-limit has to be provided")
 	}
 
-	return nil
+	plans, err := getAllPlans(app.AppType)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range plans {
+		if p.PlanId == planId {
+			return app.AppType, nil
+		}
+	}
+	return "", fmt.Errorf("Plan %v is not a plan for %v apps", planId, app.AppType)
 }
 
-func validateLogseneAppPermissions(mail string, appId int) error {
-	userApps, err := getAllLogseneAppsForUser(mail)
+// validateLogseneAppPermissions checks if the user has an active role on the app.
+// If role is ADMIN, the user must be an admin or the owner of the app.
+func validateLogseneAppPermissions(mail string, appId int, role string) error {
+	_, err := getAppForUser(mail, appId, role)
+	return err
+}
+
+// getAppForUser returns the app, if the user has an active role on it (see validateLogseneAppPermissions)
+func getAppForUser(mail string, appId int, role string) (*common.SematextAppList, error) {
+	userApps, err := getAllAppsForUser(mail, "")
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, a := range userApps {
-		if a.AppId == appId {
-			return nil
+		if a.AppId != appId {
+			continue
+		}
+		if role == sematextRoleAdmin && a.UserRole != sematextRoleAdmin && a.UserRole != sematextRoleOwner {
+			return nil, errors.New("You need the role ADMIN for this Sematext App!")
 		}
+		return &a, nil
 	}
 
-	return errors.New(noAccessError)
+	return nil, errors.New(noAccessError)
 }
 
-func getAllLogseneAppsForUser(userMail string) ([]common.SematextAppList, error) {
+// getAllAppsForUser returns the apps of the type, where the user has an active role.
+// If appType is empty, the apps of all supported types are returned.
+func getAllAppsForUser(userMail string, appType string) ([]common.SematextAppList, error) {
 	appData, err := getAllLogseneApps()
 	if err != nil {
 		return nil, err
@@ -222,7 +294,8 @@ func getAllLogseneAppsForUser(userMail string) ([]common.SematextAppList, error)
 	userApps := []common.SematextAppList{}
 	for _, app := range allApps {
 		log.Debug(app.String())
-		if app.Path("appType").Data().(string) != "Logsene" {
+		t, _ := app.Path("appType").Data().(string)
+		if _, ok := sematextAppTypes[t]; !ok || (appType != "" && t != appType) {
 			continue
 		}
 
@@ -244,6 +317,7 @@ func getAllLogseneAppsForUser(userMail string) ([]common.SematextAppList, error)
 				u := common.SematextAppList{
 					AppId:         int(app.Path("id").Data().(float64)),
 					Name:          appName,
+					AppType:       t,
 					PlanName:      app.Path("plan.name").Data().(string),
 					UserRole:      role,
 					IsFree:        app.Path("plan.free").Data().(bool),
@@ -262,8 +336,8 @@ func getAllLogseneAppsForUser(userMail string) ([]common.SematextAppList, error)
 	return userApps, nil
 }
 
-func getAllLogsenePlans() ([]common.SematextLogsenePlan, error) {
-	client, req := getSematextHTTPClient("GET", "users-web/api/v3/billing/availablePlans?appType=Logsene", nil)
+func getAllPlans(appType string) ([]common.SematextLogsenePlan, error) {
+	client, req := getSematextHTTPClient("GET", "users-web/api/v3/billing/availablePlans?appType="+url.QueryEscape(appType), nil)
 
 	resp, err := client.Do(req)
 
@@ -289,12 +363,22 @@ func getAllLogsenePlans() ([]common.SematextLogsenePlan, error) {
 
 	plans := []common.SematextLogsenePlan{}
 	for _, plan := range allPlans {
+		id, idOk := plan.Path("id").Data().(float64)
+		name, nameOk := plan.Path("name").Data().(string)
+		if !idOk || !nameOk {
+			log.Println("Skipping plan without id or name: ", plan.String())
+			continue
+		}
+		// Not every app type has all fields, e.g. Monitoring plans have no limit
+		isFree, _ := plan.Path("free").Data().(bool)
+		limit, _ := plan.Path("defaultDailyMaxLimitSizeMb").Data().(float64)
+		pricePerDay, _ := plan.Path("pricePerDay").Data().(float64)
 		plans = append(plans, common.SematextLogsenePlan{
-			PlanId:                     int(plan.Path("id").Data().(float64)),
-			Name:                       plan.Path("name").Data().(string),
-			IsFree:                     plan.Path("free").Data().(bool),
-			DefaultDailyMaxLimitSizeMb: plan.Path("defaultDailyMaxLimitSizeMb").Data().(float64),
-			PricePerMonth:              round(30*plan.Path("pricePerDay").Data().(float64), 0.05),
+			PlanId:                     int(id),
+			Name:                       name,
+			IsFree:                     isFree,
+			DefaultDailyMaxLimitSizeMb: limit,
+			PricePerMonth:              round(30*pricePerDay, 0.05),
 		})
 	}
 
@@ -332,7 +416,7 @@ func createLogseneAppAndInviteUser(username string, mail string, data common.Cre
 		return err
 	}
 
-	if err := updateLogsenePlanAndLimit(username, data.PlanId, data.Limit, appId); err != nil {
+	if err := updateLogsenePlanAndLimit(username, data.AppType, data.PlanId, data.Limit, appId); err != nil {
 		return err
 	}
 
@@ -340,7 +424,7 @@ func createLogseneAppAndInviteUser(username string, mail string, data common.Cre
 		return err
 	}
 
-	if err := inviteUserToApp(mail, appId); err != nil {
+	if err := inviteUserToApp(mail, sematextRoleAdmin, appId); err != nil {
 		return err
 	}
 
@@ -355,9 +439,9 @@ func createLogseneApp(username string, data common.CreateLogseneAppCommand) (int
 	j.Set(data.AppName, "name")
 	j.Set(data.PlanId, "initialPlanId")
 	j.Set(data.DiscountCode, "discountCode")
-	j.Set("Logsene", "appType")
+	j.Set(data.AppType, "appType")
 
-	client, req := getSematextHTTPClient("POST", "logsene-reports/api/v3/apps", bytes.NewReader(j.Bytes()))
+	client, req := getSematextHTTPClient("POST", sematextAppTypes[data.AppType], bytes.NewReader(j.Bytes()))
 	resp, err := client.Do(req)
 
 	if err != nil {
@@ -393,12 +477,12 @@ func createLogseneApp(username string, data common.CreateLogseneAppCommand) (int
 	return -1, errors.New(genericAPIError)
 }
 
-func inviteUserToApp(mail string, appId int) error {
+func inviteUserToApp(mail string, role string, appId int) error {
 	fmt.Sprintf("Inviting %v to logsene app %v.", mail, appId)
 
 	j := gabs.New()
 	j.Set(mail, "inviteeEmail")
-	j.Set(sematextRoleAdmin, "inviteeRole")
+	j.Set(role, "inviteeRole")
 
 	newAppId := gabs.New()
 	newAppId.Set(appId, "id")
@@ -451,11 +535,16 @@ func updateLogseneBilling(username string, billing string, project string, appId
 	return errors.New(genericAPIError)
 }
 
-func updateLogsenePlanAndLimit(username string, planId int, limit int, appId int) error {
+// updateLogsenePlanAndLimit changes the plan of the app. The limit is only set for Logsene apps.
+func updateLogsenePlanAndLimit(username string, appType string, planId int, limit int, appId int) error {
 	if err := updateLogsenePlan(username, planId, appId); err != nil {
 		return err
 	}
 
+	if appType != sematextAppTypeLogsene {
+		return nil
+	}
+
 	if err := updateLogseneLimit(username, limit, appId); err != nil {
 		return err
 	}
@@ -0,0 +1,113 @@
+package sematext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetAllAppsForUser(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": testApps})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		appType     string
+		expectedIDs []int
+	}{
+		{"", []int{1, 4}},
+		{sematextAppTypeLogsene, []int{1}},
+		{sematextAppTypeMonitoring, []int{4}},
+	}
+	for _, tt := range testsets {
+		apps, err := getAllAppsForUser("user@sbb.ch", tt.appType)
+		if err != nil {
+			t.Errorf("ERROR! app type %v: unexpected error: %v", tt.appType, err)
+			continue
+		}
+		ids := []int{}
+		for _, a := range apps {
+			ids = append(ids, a.AppId)
+		}
+		if !reflect.DeepEqual(ids, tt.expectedIDs) {
+			t.Errorf("ERROR! app type %v: expected apps %v, got %v", tt.appType, tt.expectedIDs, ids)
+		}
+	}
+
+	apps, _ := getAllAppsForUser("user@sbb.ch", sematextAppTypeMonitoring)
+	if len(apps) != 1 || apps[0].AppType != sematextAppTypeMonitoring || apps[0].BillingInfo != "1234 / project" {
+		t.Errorf("ERROR! expected monitoring app with billing info, got %+v", apps)
+	}
+}
+
+func TestGetAllPlans(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{
+		"/users-web/api/v3/billing/availablePlans?appType=Monitoring": `{"data": {"availablePlans": [
+			{"id": 7, "name": "Standard", "free": false, "pricePerDay": 1.0},
+			{"name": "Broken"}
+		]}}`,
+	})
+	defer sematext.Close()
+
+	// Missing fields are no error, plans without id are skipped
+	plans, err := getAllPlans(sematextAppTypeMonitoring)
+	if err != nil || len(plans) != 1 || plans[0].PlanId != 7 || plans[0].PricePerMonth != 30 {
+		t.Errorf("ERROR! expected plan 7, got %+v (%v)", plans, err)
+	}
+}
+
+func TestValidateNewLogseneApp(t *testing.T) {
+	var testsets = []struct {
+		appType   string
+		limit     int
+		expectErr bool
+	}{
+		{sematextAppTypeLogsene, 100, false},
+		{sematextAppTypeLogsene, 0, true},
+		{sematextAppTypeMonitoring, 100, false},
+		// Monitoring apps have no limit
+		{sematextAppTypeMonitoring, 0, false},
+		{"Experience", 100, true},
+		{"", 100, true},
+	}
+	for _, tt := range testsets {
+		err := validateNewLogseneApp("app", tt.appType, 1, tt.limit, "project", "1234")
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! app type %v, limit %v: expected error: %v, got: %v", tt.appType, tt.limit, tt.expectErr, err)
+		}
+	}
+}
+
+func TestValidateLogsenePlanAndLimitEdit(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{
+		"/users-web/api/v3/apps/users": testApps,
+		"/users-web/api/v3/billing/availablePlans?appType=Logsene": `{"data": {"availablePlans": [
+			{"id": 1, "name": "Basic", "free": false, "defaultDailyMaxLimitSizeMb": 500, "pricePerDay": 1.0}
+		]}}`,
+		"/users-web/api/v3/billing/availablePlans?appType=Monitoring": `{"data": {"availablePlans": [
+			{"id": 7, "name": "Standard", "free": false, "pricePerDay": 1.0}
+		]}}`,
+	})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		appId           int
+		planId          int
+		limit           int
+		expectedAppType string
+		expectErr       bool
+	}{
+		{1, 1, 100, sematextAppTypeLogsene, false},
+		{1, 1, 0, "", true},
+		// Plan of another app type
+		{1, 7, 100, "", true},
+		{4, 7, 0, sematextAppTypeMonitoring, false},
+		{4, 1, 0, "", true},
+		// No access
+		{2, 1, 100, "", true},
+	}
+	for _, tt := range testsets {
+		appType, err := validateLogsenePlanAndLimitEdit("user@sbb.ch", tt.appId, tt.planId, tt.limit)
+		if (err != nil) != tt.expectErr || appType != tt.expectedAppType {
+			t.Errorf("ERROR! app %v, plan %v, limit %v: expected %v (error: %v), got %v (%v)", tt.appId, tt.planId, tt.limit, tt.expectedAppType, tt.expectErr, appType, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package sematext
 
 import (
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net/http"
@@ -14,13 +15,43 @@ const (
 	wrongAPIUsageError = "Invalid api call - parameters did not match to method definition"
 )
 
+const (
+	sematextAppTypeLogsene    = "Logsene"
+	sematextAppTypeMonitoring = "Monitoring"
+)
+
+// sematextAppTypes are the supported app types and the Sematext API to create them.
+// Apps of other types are ignored.
+var sematextAppTypes = map[string]string{
+	sematextAppTypeLogsene:    "logsene-reports/api/v3/apps",
+	sematextAppTypeMonitoring: "spm-reports/api/v3/apps",
+}
+
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/sematext/plans", getLogsenePlansHandler)
 	r.GET("/sematext/discountcode", getLogseneDiscountcodeHandler)
 	r.GET("/sematext/logsene", getLogseneAppsHandler)
 	r.POST("/sematext/logsene", createLogseneAppHandler)
-	r.POST("/sematext/logsene/:appId", updateLogseneBillingHandler)
-	r.POST("/sematext/logsene/:appId/plan", updateLogsenePlanAndLimitHandler)
+	r.GET("/sematext/apps", getAppsHandler)
+	r.GET("/sematext/logsene/:appId/usage", getLogseneUsageHandler)
+	r.GET("/sematext/chargeback", getChargebackHandler)
+	r.POST("/sematext/apps", createAppHandler)
+	// The app id is unique over all app types
+	for _, prefix := range []string{"/sematext/logsene", "/sematext/apps"} {
+		r.POST(prefix+"/:appId", updateLogseneBillingHandler)
+		r.POST(prefix+"/:appId/plan", updateLogsenePlanAndLimitHandler)
+		r.DELETE(prefix+"/:appId", deleteLogseneAppHandler)
+		r.GET(prefix+"/:appId/users", getLogseneAppUsersHandler)
+		r.POST(prefix+"/:appId/users", inviteLogseneAppUserHandler)
+		r.DELETE(prefix+"/:appId/users/:mail", revokeLogseneAppUserHandler)
+	}
+}
+
+func validateAppType(appType string) error {
+	if _, ok := sematextAppTypes[appType]; !ok {
+		return fmt.Errorf("App type %v is not supported. Supported: %v, %v", appType, sematextAppTypeLogsene, sematextAppTypeMonitoring)
+	}
+	return nil
 }
 
 func getSematextHTTPClient(method string, urlPart string, body io.Reader) (*http.Client, *http.Request) {
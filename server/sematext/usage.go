@@ -0,0 +1,177 @@
+package sematext
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/gabs"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	usageDefaultDays = 30
+	usageMaxDays     = 90
+	// The recommended limit is the highest daily volume plus headroom,
+	// rounded up to steps of limitStepMb
+	limitHeadroom = 1.2
+	limitStepMb   = 100
+)
+
+func getLogseneUsageHandler(c *gin.Context) {
+	mail := common.GetUserMail(c)
+	appId, err := strconv.Atoi(c.Param("appId"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	days := usageDefaultDays
+	if d := c.Query("days"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil || days < 1 || days > usageMaxDays {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: fmt.Sprintf("Days must be between 1 and %v", usageMaxDays)})
+			return
+		}
+	}
+
+	if err := validateLogseneUsageApp(mail, appId); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	if usage, err := getLogseneUsage(appId, days, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, usage)
+	}
+}
+
+// validateLogseneUsageApp checks, that the user has access to the app and that it is a Logsene app.
+// Only Logsene apps have a daily volume and limit.
+func validateLogseneUsageApp(mail string, appId int) error {
+	app, err := getAppForUser(mail, appId, "")
+	if err != nil {
+		return err
+	}
+	if app.AppType != sematextAppTypeLogsene {
+		return fmt.Errorf("The usage is only available for %v apps", sematextAppTypeLogsene)
+	}
+	return nil
+}
+
+// getLogseneUsage returns the daily volume of the last days before now,
+// how often the limit was reached and a recommendation for the limit
+func getLogseneUsage(appId int, days int, now time.Time) (common.SematextAppUsage, error) {
+	to := now.UTC().Format("2006-01-02")
+	from := now.UTC().AddDate(0, 0, -days+1).Format("2006-01-02")
+
+	dailyUsage, err := getDailyUsage(appId, from, to)
+	if err != nil {
+		return common.SematextAppUsage{}, err
+	}
+
+	usage := common.SematextAppUsage{
+		AppId: appId,
+		Days:  days,
+		Usage: dailyUsage,
+	}
+	for _, u := range dailyUsage {
+		if u.LimitReached {
+			usage.LimitReachedDays++
+		}
+	}
+	if len(dailyUsage) > 0 {
+		usage.CurrentLimitMb = dailyUsage[len(dailyUsage)-1].LimitMb
+	}
+	usage.RecommendedLimitMb, usage.Recommendation = getLimitRecommendation(dailyUsage, usage.CurrentLimitMb, usage.LimitReachedDays)
+
+	return usage, nil
+}
+
+func getDailyUsage(appId int, from string, to string) ([]common.SematextDailyUsage, error) {
+	client, req := getSematextHTTPClient("GET", fmt.Sprintf("logsene-reports/api/v3/apps/%v/usage/%v/%v", appId, from, to), nil)
+	resp, err := client.Do(req)
+
+	if err != nil {
+		log.Println("Error from Sematext API: ", err.Error())
+		return nil, errors.New(genericAPIError)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		log.Println("GetDailyUsage: Sematext response status code was: ", resp.StatusCode, string(bodyBytes))
+		return nil, errors.New(genericAPIError)
+	}
+
+	json, err := gabs.ParseJSONBuffer(resp.Body)
+	if err != nil {
+		log.Println("error parsing body of response:", err)
+		return nil, errors.New(genericAPIError)
+	}
+
+	usage := []common.SematextDailyUsage{}
+	// Days without data are missing
+	days, _ := json.Path("data.usage.days").Children()
+	for _, day := range days {
+		u := common.SematextDailyUsage{}
+		u.Date, _ = day.S("date").Data().(string)
+		u.VolumeMb, _ = day.S("volumeMb").Data().(float64)
+		u.LimitMb, _ = day.S("maxLimitMb").Data().(float64)
+		u.LimitReached, _ = day.S("limitReached").Data().(bool)
+		if u.LimitMb > 0 && u.VolumeMb >= u.LimitMb {
+			u.LimitReached = true
+		}
+		usage = append(usage, u)
+	}
+
+	return usage, nil
+}
+
+// getLimitRecommendation recommends a daily limit, which is enough for the highest
+// daily volume. If the limit was reached, the volume of these days is unknown,
+// so at least the current limit plus headroom is recommended.
+// A currentLimit of 0 means, that the app has no daily limit.
+func getLimitRecommendation(usage []common.SematextDailyUsage, currentLimit float64, limitReachedDays int) (int, string) {
+	if len(usage) == 0 {
+		return int(currentLimit), "There is not enough data for a recommendation."
+	}
+
+	maxVolume := 0.0
+	for _, u := range usage {
+		maxVolume = math.Max(maxVolume, u.VolumeMb)
+	}
+	if limitReachedDays > 0 {
+		maxVolume = math.Max(maxVolume, currentLimit)
+	}
+	recommended := int(math.Ceil(maxVolume*limitHeadroom/limitStepMb)) * limitStepMb
+	if recommended < limitStepMb {
+		recommended = limitStepMb
+	}
+
+	if currentLimit == 0 {
+		return recommended, fmt.Sprintf("The app has no daily limit. At most %v MB per day were used, a limit of %v MB fits the daily volume.",
+			math.Round(maxVolume), recommended)
+	}
+	if limitReachedDays > 0 {
+		return recommended, fmt.Sprintf("The limit was reached on %v of %v days. Logs were dropped. Increase the limit to %v MB.",
+			limitReachedDays, len(usage), recommended)
+	}
+	if float64(recommended) > currentLimit {
+		return recommended, fmt.Sprintf("The daily volume is close to the limit. Increase the limit to %v MB.", recommended)
+	}
+	if float64(recommended) < currentLimit/2 {
+		return recommended, fmt.Sprintf("At most %v MB per day were used. The limit can be reduced to %v MB.",
+			math.Round(maxVolume), recommended)
+	}
+	return int(currentLimit), "The limit fits the daily volume."
+}
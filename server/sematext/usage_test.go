@@ -0,0 +1,87 @@
+package sematext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+)
+
+func TestGetLogseneUsage(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{
+		"/logsene-reports/api/v3/apps/1/usage/2020-08-01/2020-08-03": `{"data": {"usage": {"days": [
+			{"date": "2020-08-01", "volumeMb": 80, "maxLimitMb": 100, "limitReached": false},
+			{"date": "2020-08-02", "volumeMb": 100, "maxLimitMb": 100, "limitReached": false},
+			{"date": "2020-08-03", "volumeMb": 100, "maxLimitMb": 100, "limitReached": true}
+		]}}}`,
+	})
+	defer sematext.Close()
+
+	usage, err := getLogseneUsage(1, 3, time.Date(2020, 8, 3, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	if len(usage.Usage) != 3 || usage.LimitReachedDays != 2 || usage.CurrentLimitMb != 100 || usage.RecommendedLimitMb != 200 {
+		t.Errorf("ERROR! unexpected usage: %+v", usage)
+	}
+
+	if _, err := getLogseneUsage(2, 3, time.Date(2020, 8, 3, 12, 0, 0, 0, time.UTC)); err == nil {
+		t.Errorf("ERROR! expected error for unknown app")
+	}
+}
+
+func TestValidateLogseneUsageApp(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": testApps})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		appId     int
+		expectErr bool
+	}{
+		{1, false},
+		// Monitoring app
+		{4, true},
+		// No access
+		{2, true},
+	}
+	for _, tt := range testsets {
+		err := validateLogseneUsageApp("user@sbb.ch", tt.appId)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! app %v: expected error: %v, got: %v", tt.appId, tt.expectErr, err)
+		}
+	}
+}
+
+func TestGetLimitRecommendation(t *testing.T) {
+	day := func(volume float64) common.SematextDailyUsage {
+		return common.SematextDailyUsage{VolumeMb: volume, LimitMb: 1000}
+	}
+	var testsets = []struct {
+		usage            []common.SematextDailyUsage
+		currentLimit     float64
+		limitReachedDays int
+		expected         int
+	}{
+		{nil, 1000, 0, 1000},
+		// fits
+		{[]common.SematextDailyUsage{day(500), day(700)}, 1000, 0, 1000},
+		// close to the limit
+		{[]common.SematextDailyUsage{day(500), day(900)}, 1000, 0, 1100},
+		// reached, the real volume is unknown
+		{[]common.SematextDailyUsage{day(500), day(1000)}, 1000, 1, 1200},
+		// too high
+		{[]common.SematextDailyUsage{day(10), day(30)}, 1000, 0, 100},
+		{[]common.SematextDailyUsage{day(200), day(300)}, 1000, 0, 400},
+		{[]common.SematextDailyUsage{day(300), day(350)}, 1000, 0, 1000},
+		// no limit
+		{nil, 0, 0, 0},
+		{[]common.SematextDailyUsage{{VolumeMb: 300}, {VolumeMb: 350}}, 0, 0, 500},
+		{[]common.SematextDailyUsage{{VolumeMb: 0}}, 0, 0, 100},
+	}
+	for i, tt := range testsets {
+		recommended, msg := getLimitRecommendation(tt.usage, tt.currentLimit, tt.limitReachedDays)
+		if recommended != tt.expected || msg == "" {
+			t.Errorf("ERROR! testset %v: expected %v, got %v (%v)", i, tt.expected, recommended, msg)
+		}
+	}
+}
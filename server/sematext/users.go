@@ -0,0 +1,253 @@
+package sematext
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func getLogseneAppUsersHandler(c *gin.Context) {
+	mail := common.GetUserMail(c)
+	appId, err := strconv.Atoi(c.Param("appId"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	if err := validateLogseneAppPermissions(mail, appId, ""); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	if users, err := getLogseneAppUsers(appId); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+func inviteLogseneAppUserHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	mail := common.GetUserMail(c)
+	appId, err := strconv.Atoi(c.Param("appId"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	var data common.InviteSematextUserCommand
+	if c.BindJSON(&data) == nil {
+		if err := validateLogseneAppUserInvite(mail, appId, data.Mail, data.Role); err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+			return
+		}
+
+		log.Printf("User %v invites %v with role %v to logsene app %v", username, data.Mail, data.Role, appId)
+		if err := inviteUserToApp(data.Mail, data.Role, appId); err != nil {
+			c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		} else {
+			c.JSON(http.StatusOK, common.ApiResponse{
+				Message: fmt.Sprintf("%v has been invited as %v.", data.Mail, data.Role),
+			})
+		}
+	} else {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+	}
+}
+
+func revokeLogseneAppUserHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	mail := common.GetUserMail(c)
+	revokeMail := c.Param("mail")
+	appId, err := strconv.Atoi(c.Param("appId"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	if err := validateLogseneAppUserRevoke(mail, appId, revokeMail); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("User %v revokes %v from logsene app %v", username, revokeMail, appId)
+	if err := revokeUserFromApp(revokeMail, appId); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, common.ApiResponse{
+			Message: fmt.Sprintf("%v has been removed from the app.", revokeMail),
+		})
+	}
+}
+
+func deleteLogseneAppHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	mail := common.GetUserMail(c)
+	appId, err := strconv.Atoi(c.Param("appId"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: wrongAPIUsageError})
+		return
+	}
+
+	if err := validateLogseneAppPermissions(mail, appId, sematextRoleAdmin); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+
+	log.Printf("User %v deletes logsene app %v", username, appId)
+	if err := deleteLogseneApp(appId); err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+	} else {
+		c.JSON(http.StatusOK, common.ApiResponse{
+			Message: fmt.Sprintf("Logsene App %v has been deleted.", appId),
+		})
+	}
+}
+
+func validateLogseneAppUserInvite(mail string, appId int, inviteeMail string, role string) error {
+	// Check permissions
+	if err := validateLogseneAppPermissions(mail, appId, sematextRoleAdmin); err != nil {
+		return err
+	}
+
+	// Check values
+	if !strings.Contains(inviteeMail, "@") {
+		return errors.New("Please provide a valid mail address")
+	}
+
+	if role != sematextRoleAdmin && role != sematextRoleUser {
+		return fmt.Errorf("Role must be %v or %v", sematextRoleAdmin, sematextRoleUser)
+	}
+
+	return nil
+}
+
+func validateLogseneAppUserRevoke(mail string, appId int, revokeMail string) error {
+	// Check permissions
+	if err := validateLogseneAppPermissions(mail, appId, sematextRoleAdmin); err != nil {
+		return err
+	}
+
+	users, err := getLogseneAppUsers(appId)
+	if err != nil {
+		return err
+	}
+
+	var revokeUser *common.SematextAppUser
+	admins := 0
+	for i, u := range users {
+		if strings.EqualFold(u.Mail, revokeMail) {
+			revokeUser = &users[i]
+		}
+		if u.Status == sematextRoleActive && (u.Role == sematextRoleAdmin || u.Role == sematextRoleOwner) {
+			admins++
+		}
+	}
+
+	if revokeUser == nil {
+		return fmt.Errorf("%v is not a user of this app", revokeMail)
+	}
+
+	if revokeUser.Role == sematextRoleOwner {
+		return errors.New("The owner of the app cannot be removed")
+	}
+
+	// The app must still be manageable
+	if revokeUser.Role == sematextRoleAdmin && revokeUser.Status == sematextRoleActive && admins <= 1 {
+		return errors.New("The last administrator of the app cannot be removed")
+	}
+
+	return nil
+}
+
+func getLogseneAppUsers(appId int) ([]common.SematextAppUser, error) {
+	appData, err := getAllLogseneApps()
+	if err != nil {
+		return nil, err
+	}
+
+	app := findLogseneApp(appData, appId)
+	if app == nil {
+		return nil, errors.New(noAccessError)
+	}
+
+	users := []common.SematextAppUser{}
+	userRoles, err := app.Path("userRoles").Children()
+	if err != nil {
+		return users, nil
+	}
+	for _, userRole := range userRoles {
+		u := common.SematextAppUser{}
+		u.Mail, _ = userRole.S("userEmail").Data().(string)
+		u.Role, _ = userRole.S("role").Data().(string)
+		u.Status, _ = userRole.S("roleStatus").Data().(string)
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func revokeUserFromApp(mail string, appId int) error {
+	client, req := getSematextHTTPClient("DELETE", "users-web/api/v3/apps/"+strconv.Itoa(appId)+"/users/"+url.PathEscape(mail), nil)
+	resp, err := client.Do(req)
+
+	if err != nil {
+		log.Println("Error from Sematext API: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	log.Println("RevokeUserFromApp: Sematext response status code was: ", resp.StatusCode, string(bodyBytes))
+
+	return errors.New(genericAPIError)
+}
+
+func deleteLogseneApp(appId int) error {
+	client, req := getSematextHTTPClient("DELETE", "users-web/api/v3/apps/"+strconv.Itoa(appId), nil)
+	resp, err := client.Do(req)
+
+	if err != nil {
+		log.Println("Error from Sematext API: ", err.Error())
+		return errors.New(genericAPIError)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	log.Println("DeleteLogseneApp: Sematext response status code was: ", resp.StatusCode, string(bodyBytes))
+
+	return errors.New(genericAPIError)
+}
+
+// findLogseneApp returns the app with the id from the response of getAllLogseneApps
+func findLogseneApp(appData *gabs.Container, appId int) *gabs.Container {
+	allApps, _ := appData.Path("data.apps").Children()
+	for _, app := range allApps {
+		if id, _ := app.Path("id").Data().(float64); int(id) == appId {
+			return app
+		}
+	}
+	return nil
+}
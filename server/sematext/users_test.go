@@ -0,0 +1,117 @@
+package sematext
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/testutil"
+)
+
+// newFakeSematext starts a Sematext API, which returns the responses by path
+func newFakeSematext(t *testing.T, responses map[string]string) *httptest.Server {
+	server := testutil.NewFakeAPI(responses)
+	config.Init("bla")
+	config.Config().Set("sematext_base_url", server.URL)
+	config.Config().Set("sematext_api_token", "token")
+	return server
+}
+
+const testApps = `{"data": {"apps": [
+	{"id": 1, "name": "app1", "appType": "Logsene", "plan": {"name": "Basic", "free": false, "pricePerDay": 1.0}, "userRoles": [
+		{"userEmail": "owner@sbb.ch", "role": "OWNER", "roleStatus": "ACTIVE"},
+		{"userEmail": "admin@sbb.ch", "role": "ADMIN", "roleStatus": "ACTIVE"},
+		{"userEmail": "user@sbb.ch", "role": "USER", "roleStatus": "ACTIVE"}
+	]},
+	{"id": 2, "name": "app2", "appType": "Logsene", "plan": {"name": "Basic", "free": false, "pricePerDay": 1.0}, "userRoles": [
+		{"userEmail": "admin@sbb.ch", "role": "ADMIN", "roleStatus": "ACTIVE"},
+		{"userEmail": "invited@sbb.ch", "role": "ADMIN", "roleStatus": "INVITED"}
+	]},
+	{"id": 4, "name": "app4", "appType": "Monitoring", "description": "1234 / project", "plan": {"name": "Standard", "free": true, "pricePerDay": 0.0}, "userRoles": [
+		{"userEmail": "user@sbb.ch", "role": "ADMIN", "roleStatus": "ACTIVE"}
+	]},
+	{"id": 5, "name": "app5", "appType": "Experience", "plan": {"name": "Basic", "free": false, "pricePerDay": 1.0}, "userRoles": [
+		{"userEmail": "user@sbb.ch", "role": "ADMIN", "roleStatus": "ACTIVE"}
+	]}
+]}}`
+
+func TestValidateLogseneAppPermissions(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": testApps})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		mail      string
+		appId     int
+		role      string
+		expectErr bool
+	}{
+		{"user@sbb.ch", 1, "", false},
+		{"USER@sbb.ch", 1, "", false},
+		{"user@sbb.ch", 1, sematextRoleAdmin, true},
+		{"admin@sbb.ch", 1, sematextRoleAdmin, false},
+		{"owner@sbb.ch", 1, sematextRoleAdmin, false},
+		{"user@sbb.ch", 2, "", true},
+		{"invited@sbb.ch", 2, "", true},
+		{"admin@sbb.ch", 3, "", true},
+		{"user@sbb.ch", 4, sematextRoleAdmin, false},
+		// Unsupported app types are ignored
+		{"user@sbb.ch", 5, "", true},
+	}
+	for _, tt := range testsets {
+		err := validateLogseneAppPermissions(tt.mail, tt.appId, tt.role)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %v app %v role %v: expected error: %v, got: %v", tt.mail, tt.appId, tt.role, tt.expectErr, err)
+		}
+	}
+}
+
+func TestValidateLogseneAppUserInvite(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": testApps})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		mail        string
+		inviteeMail string
+		role        string
+		expectErr   bool
+	}{
+		{"admin@sbb.ch", "new@sbb.ch", "USER", false},
+		{"admin@sbb.ch", "new@sbb.ch", "ADMIN", false},
+		{"admin@sbb.ch", "new@sbb.ch", "OWNER", true},
+		{"admin@sbb.ch", "new", "USER", true},
+		{"user@sbb.ch", "new@sbb.ch", "USER", true},
+	}
+	for _, tt := range testsets {
+		err := validateLogseneAppUserInvite(tt.mail, 1, tt.inviteeMail, tt.role)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %v invites %v as %v: expected error: %v, got: %v", tt.mail, tt.inviteeMail, tt.role, tt.expectErr, err)
+		}
+	}
+}
+
+func TestValidateLogseneAppUserRevoke(t *testing.T) {
+	sematext := newFakeSematext(t, map[string]string{"/users-web/api/v3/apps/users": testApps})
+	defer sematext.Close()
+
+	var testsets = []struct {
+		mail       string
+		appId      int
+		revokeMail string
+		expectErr  bool
+	}{
+		{"admin@sbb.ch", 1, "user@sbb.ch", false},
+		{"admin@sbb.ch", 1, "admin@sbb.ch", false},
+		{"admin@sbb.ch", 1, "owner@sbb.ch", true},
+		{"admin@sbb.ch", 1, "unknown@sbb.ch", true},
+		{"user@sbb.ch", 1, "admin@sbb.ch", true},
+		// The invited admin is not active yet
+		{"admin@sbb.ch", 2, "admin@sbb.ch", true},
+		{"admin@sbb.ch", 2, "invited@sbb.ch", false},
+	}
+	for _, tt := range testsets {
+		err := validateLogseneAppUserRevoke(tt.mail, tt.appId, tt.revokeMail)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! %v revokes %v from app %v: expected error: %v, got: %v", tt.mail, tt.revokeMail, tt.appId, tt.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package testutil contains helpers for the tests of the API packages
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// NewFakeAPI starts an API, which returns the responses by path and query.
+// Requests without a response get 404.
+func NewFakeAPI(responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
@@ -0,0 +1,241 @@
+package tower
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	jobListDefaultPageSize = 20
+	jobListMaxPageSize     = 100
+	// Max. page size of Tower
+	jobListTowerPageSize = 200
+)
+
+var jobStatuses = []string{"new", "pending", "waiting", "running", "successful", "failed", "error", "canceled"}
+
+type JobListResponse struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	HasMore  bool          `json:"has_more"`
+	Results  []interface{} `json:"results"`
+}
+
+// jobListFilter contains the query parameters of the job list
+type jobListFilter struct {
+	page          int
+	pageSize      int
+	jobType       string
+	status        string
+	template      string
+	createdAfter  time.Time
+	createdBefore time.Time
+}
+
+// jobSource is a Tower endpoint, which is part of the job list
+type jobSource struct {
+	jobType        string
+	path           string
+	templateFilter string
+	// userFilter returns the query parameter, which filters the jobs of the user in Tower
+	userFilter func(username string) (string, string)
+}
+
+var jobSources = []jobSource{
+	{jobType: "job", path: "jobs", templateFilter: "job_template", userFilter: func(username string) (string, string) {
+		return "skip_tags", "ssp_filter_" + username
+	}},
+	// Workflow jobs have no skip tags. Tower saves the extra_vars as json string,
+	// so the username set by the SSP can be found by its key and value.
+	{jobType: "workflow_job", path: "workflow_jobs", templateFilter: "workflow_job_template", userFilter: func(username string) (string, string) {
+		return "extra_vars__contains", fmt.Sprintf(`"custom_tower_user_name": "%v"`, username)
+	}},
+}
+
+func getJobsHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+
+	filter, err := parseJobListFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: err.Error()})
+		return
+	}
+	jobs, err := listJobs(username, filter)
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+func parseJobListFilter(query url.Values) (jobListFilter, error) {
+	filter := jobListFilter{
+		page:     1,
+		pageSize: jobListDefaultPageSize,
+		jobType:  query.Get("type"),
+		status:   query.Get("status"),
+		template: query.Get("template"),
+	}
+	var err error
+	if p := query.Get("page"); p != "" {
+		filter.page, err = strconv.Atoi(p)
+		if err != nil || filter.page < 1 {
+			return filter, fmt.Errorf("Ungültige Seite: %v", p)
+		}
+	}
+	if p := query.Get("page_size"); p != "" {
+		filter.pageSize, err = strconv.Atoi(p)
+		if err != nil || filter.pageSize < 1 || filter.pageSize > jobListMaxPageSize {
+			return filter, fmt.Errorf("Ungültige Seitengrösse: %v (max. %v)", p, jobListMaxPageSize)
+		}
+	}
+	if filter.jobType != "" && filter.jobType != "job" && filter.jobType != "workflow_job" {
+		return filter, fmt.Errorf("Ungültiger Typ: %v. Erlaubt: job, workflow_job", filter.jobType)
+	}
+	if filter.status != "" && !contains(jobStatuses, filter.status) {
+		return filter, fmt.Errorf("Ungültiger Status: %v", filter.status)
+	}
+	if filter.template != "" {
+		if _, err := strconv.Atoi(filter.template); err != nil {
+			return filter, fmt.Errorf("Ungültiges Template: %v", filter.template)
+		}
+	}
+	if filter.createdAfter, err = parseJobListDate(query.Get("created_after")); err != nil {
+		return filter, err
+	}
+	if filter.createdBefore, err = parseJobListDate(query.Get("created_before")); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseJobListDate accepts a date (2006-01-02) or a timestamp (RFC3339)
+func parseJobListDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Ungültiges Datum: %v. Format: 2006-01-02 oder 2006-01-02T15:04:05Z", s)
+	}
+	return t, nil
+}
+
+// listJobs returns a page of the jobs of the user, newest first.
+// The jobs of all sources are merged by their creation date.
+func listJobs(username string, filter jobListFilter) (JobListResponse, error) {
+	// All jobs up to the end of the page are needed from every source to merge them
+	needed := filter.page * filter.pageSize
+	var jobs []*gabs.Container
+	hasMore := false
+	for _, s := range jobSources {
+		if filter.jobType != "" && filter.jobType != s.jobType {
+			continue
+		}
+		sourceJobs, more, err := getJobsOfSource(s, username, filter, needed)
+		if err != nil {
+			return JobListResponse{}, err
+		}
+		jobs = append(jobs, sourceJobs...)
+		hasMore = hasMore || more
+	}
+	// Tower returns the date as RFC3339 in UTC, so they can be compared as strings
+	sort.SliceStable(jobs, func(i, j int) bool {
+		created1, _ := jobs[i].S("created").Data().(string)
+		created2, _ := jobs[j].S("created").Data().(string)
+		return created1 > created2
+	})
+
+	response := JobListResponse{
+		Page:     filter.page,
+		PageSize: filter.pageSize,
+		HasMore:  hasMore || len(jobs) > needed,
+		Results:  []interface{}{},
+	}
+	for i := needed - filter.pageSize; i < needed && i < len(jobs); i++ {
+		response.Results = append(response.Results, jobs[i].Data())
+	}
+	return response, nil
+}
+
+// getJobsOfSource pages through the jobs of the user in Tower, until it has the needed number of jobs.
+// The bool is true, if there are more jobs.
+func getJobsOfSource(s jobSource, username string, filter jobListFilter, needed int) ([]*gabs.Container, bool, error) {
+	query := url.Values{}
+	query.Set("order_by", "-created")
+	query.Set(s.userFilter(username))
+	// One more shows if there are more jobs
+	pageSize := jobListTowerPageSize
+	if needed+1 < pageSize {
+		pageSize = needed + 1
+	}
+	query.Set("page_size", strconv.Itoa(pageSize))
+	if filter.status != "" {
+		query.Set("status", filter.status)
+	}
+	if filter.template != "" {
+		query.Set(s.templateFilter, filter.template)
+	}
+	if !filter.createdAfter.IsZero() {
+		query.Set("created__gte", filter.createdAfter.UTC().Format(time.RFC3339))
+	}
+	if !filter.createdBefore.IsZero() {
+		query.Set("created__lt", filter.createdBefore.UTC().Format(time.RFC3339))
+	}
+
+	var jobs []*gabs.Container
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		results, hasNext, err := getJobsPage(s.path + "/?" + query.Encode())
+		if err != nil {
+			return nil, false, err
+		}
+		for _, job := range results {
+			// Tower filters the jobs, this only makes sure no other jobs are shown
+			if isJobLaunchedBy(job, username) {
+				jobs = append(jobs, job)
+			}
+		}
+		if len(jobs) > needed {
+			return jobs[:needed], true, nil
+		}
+		if !hasNext {
+			return jobs, false, nil
+		}
+	}
+}
+
+// getJobsPage returns the results of a page of Tower and if there is a next page
+func getJobsPage(urlPart string) ([]*gabs.Container, bool, error) {
+	resp, err := getTowerHTTPClient("GET", urlPart, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("Error getting %v. Tower returned status %v", urlPart, resp.StatusCode)
+	}
+	json, err := gabs.ParseJSON(body)
+	if err != nil {
+		return nil, false, err
+	}
+	return json.S("results").Children(), json.S("next").Data() != nil, nil
+}
@@ -0,0 +1,98 @@
+package tower
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJobListFilter(t *testing.T) {
+	var testsets = []struct {
+		query     string
+		expected  jobListFilter
+		expectErr bool
+	}{
+		{"", jobListFilter{page: 1, pageSize: 20}, false},
+		{"page=3&page_size=50&type=job&status=failed&template=123", jobListFilter{page: 3, pageSize: 50, jobType: "job", status: "failed", template: "123"}, false},
+		{"created_after=2020-08-01&created_before=2020-08-02T12:00:00Z", jobListFilter{
+			page:          1,
+			pageSize:      20,
+			createdAfter:  time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC),
+			createdBefore: time.Date(2020, 8, 2, 12, 0, 0, 0, time.UTC),
+		}, false},
+		{"page=0", jobListFilter{}, true},
+		{"page=a", jobListFilter{}, true},
+		{"page_size=101", jobListFilter{}, true},
+		{"type=project_update", jobListFilter{}, true},
+		{"status=done", jobListFilter{}, true},
+		{"template=1%26page_size%3D1000", jobListFilter{}, true},
+		{"created_after=01.08.2020", jobListFilter{}, true},
+	}
+	for _, tt := range testsets {
+		query, _ := url.ParseQuery(tt.query)
+		filter, err := parseJobListFilter(query)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! query %v: expected error: %v, got: %v", tt.query, tt.expectErr, err)
+			continue
+		}
+		if !tt.expectErr && !reflect.DeepEqual(filter, tt.expected) {
+			t.Errorf("ERROR! query %v: expected %+v, got %+v", tt.query, tt.expected, filter)
+		}
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/jobs/?order_by=-created&page=1&page_size=3&skip_tags=ssp_filter_u123456": `{"next": "/api/v2/jobs/?page=2", "results": [
+			{"id": 1, "type": "job", "created": "2020-08-05T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"},
+			{"id": 2, "type": "job", "created": "2020-08-03T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"},
+			{"id": 3, "type": "job", "created": "2020-08-01T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"}
+		]}`,
+		"/api/v2/jobs/?order_by=-created&page=1&page_size=5&skip_tags=ssp_filter_u123456": `{"next": null, "results": [
+			{"id": 1, "type": "job", "created": "2020-08-05T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"},
+			{"id": 2, "type": "job", "created": "2020-08-03T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"},
+			{"id": 3, "type": "job", "created": "2020-08-01T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"}
+		]}`,
+		"/api/v2/jobs/?order_by=-created&page=1&page_size=3&skip_tags=ssp_filter_u123456&status=failed": `{"next": null, "results": [
+			{"id": 2, "type": "job", "created": "2020-08-03T10:00:00.000000Z", "skip_tags": "ssp_filter_u123456"}
+		]}`,
+		// Workflow jobs are filtered in Tower by the username in the extra_vars
+		"/api/v2/workflow_jobs/?extra_vars__contains=%22custom_tower_user_name%22%3A+%22u123456%22&order_by=-created&page=1&page_size=3": `{"next": null, "results": [
+			{"id": 11, "type": "workflow_job", "created": "2020-08-04T10:00:00.000000Z", "extra_vars": "{\"custom_tower_user_name\": \"u123456\"}"}
+		]}`,
+		"/api/v2/workflow_jobs/?extra_vars__contains=%22custom_tower_user_name%22%3A+%22u123456%22&order_by=-created&page=1&page_size=5": `{"next": null, "results": [
+			{"id": 11, "type": "workflow_job", "created": "2020-08-04T10:00:00.000000Z", "extra_vars": "{\"custom_tower_user_name\": \"u123456\"}"},
+			{"id": 10, "type": "workflow_job", "created": "2020-08-02T10:00:00.000000Z", "extra_vars": "{\"custom_tower_user_name\": \"u654321\"}"}
+		]}`,
+	})
+	defer tower.Close()
+
+	var testsets = []struct {
+		filter          jobListFilter
+		expectedIDs     []float64
+		expectedHasMore bool
+	}{
+		{jobListFilter{page: 1, pageSize: 2}, []float64{1, 11}, true},
+		{jobListFilter{page: 2, pageSize: 2}, []float64{2, 3}, false},
+		{jobListFilter{page: 4, pageSize: 1}, []float64{3}, false},
+		{jobListFilter{page: 2, pageSize: 2, jobType: "workflow_job"}, []float64{}, false},
+		{jobListFilter{page: 1, pageSize: 2, jobType: "job"}, []float64{1, 2}, true},
+		{jobListFilter{page: 1, pageSize: 2, jobType: "workflow_job"}, []float64{11}, false},
+		{jobListFilter{page: 1, pageSize: 2, jobType: "job", status: "failed"}, []float64{2}, false},
+	}
+	for _, tt := range testsets {
+		jobs, err := listJobs("u123456", tt.filter)
+		if err != nil {
+			t.Errorf("ERROR! filter %+v: unexpected error: %v", tt.filter, err)
+			continue
+		}
+		ids := []float64{}
+		for _, job := range jobs.Results {
+			ids = append(ids, job.(map[string]interface{})["id"].(float64))
+		}
+		if !reflect.DeepEqual(ids, tt.expectedIDs) || jobs.HasMore != tt.expectedHasMore {
+			t.Errorf("ERROR! filter %+v: expected %v (has more: %v), got %v (has more: %v)", tt.filter, tt.expectedIDs, tt.expectedHasMore, ids, jobs.HasMore)
+		}
+	}
+}
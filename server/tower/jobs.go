@@ -0,0 +1,363 @@
+package tower
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	jobPermissionError = "Du hast keine Berechtigung für diesen Job"
+	// Polling of the job events for the stdout stream
+	jobStreamPollInterval = 2 * time.Second
+	jobStreamTimeout      = 30 * time.Minute
+	jobEventsPageSize     = 200
+)
+
+var (
+	// errJobNotFound is returned, if Tower doesn't know the job
+	errJobNotFound = errors.New("Job not found")
+	// errJobPermission is returned, if the user is not allowed to see the job
+	errJobPermission = errors.New(jobPermissionError)
+	// Can be replaced in tests
+	getUserGroups = ldap.GetGroups
+)
+
+func cancelJobHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	job := c.Param("job")
+
+	_, err := checkJobOwner(job, username)
+	if err == errJobPermission {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
+		return
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	resp, err := getTowerHTTPClient("POST", "jobs/"+job+"/cancel/", nil)
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: "Der Job kann nicht mehr abgebrochen werden"})
+		return
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		log.Errorf("Error canceling job %v. Tower returned status %v", job, resp.StatusCode)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	log.Printf("Job %v canceled by %v", job, username)
+	c.JSON(http.StatusOK, common.ApiResponse{Message: "Der Job wird abgebrochen"})
+}
+
+func relaunchJobHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	job := c.Param("job")
+
+	jobData, err := checkJobOwner(job, username)
+	if err == errJobPermission {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
+		return
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	// The job template must still be allowed for the user
+	extraVars, err := getJobExtraVars(jobData)
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	launchRequest := gabs.New()
+	launchRequest.Set(extraVars.Data(), "extra_vars")
+	jobTemplate := fmt.Sprint(jobData.Search("job_template").Data())
+	if err := checkPermissions(jobTemplate, launchRequest, username); err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
+		return
+	}
+
+	resp, err := getTowerHTTPClient("POST", "jobs/"+job+"/relaunch/", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	if resp.StatusCode != http.StatusCreated {
+		log.Errorf("Error relaunching job %v. Tower returned status %v: %v", job, resp.StatusCode, string(body))
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+	log.Printf("Job %v relaunched by %v", job, username)
+	c.JSON(http.StatusOK, string(body))
+}
+
+// streamJobOutputHandler sends the stdout of the job as server-sent events.
+// Every event is a job event of Tower with its counter as id, so a client can
+// continue after a reconnect (Last-Event-ID). The stream ends with the event "end",
+// when the job is finished, or with the event "timeout" after jobStreamTimeout.
+func streamJobOutputHandler(c *gin.Context) {
+	username := common.GetUserName(c)
+	job := c.Param("job")
+
+	_, err := checkJobPermissions(job, username)
+	if err == errJobPermission {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
+		return
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("counter")
+	}
+	counter, _ := strconv.Atoi(lastEventID)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// Disable buffering in the proxy
+	c.Header("X-Accel-Buffering", "no")
+
+	deadline := time.Now().Add(jobStreamTimeout)
+	c.Stream(func(w io.Writer) bool {
+		// The status is read before the events, so no event is missed
+		jobData, err := getJob(job)
+		if err != nil {
+			log.Errorf("%v", err)
+			writeSSE(w, "", "error", genericAPIError)
+			return false
+		}
+		events, err := getJobEvents(job, counter)
+		if err != nil {
+			log.Errorf("%v", err)
+			writeSSE(w, "", "error", genericAPIError)
+			return false
+		}
+		for _, e := range events {
+			counter = e.counter
+			writeSSE(w, strconv.Itoa(e.counter), "stdout", e.stdout)
+		}
+		if len(events) == jobEventsPageSize {
+			// There are more events
+			return true
+		}
+		if isJobFinished(jobData) {
+			writeSSE(w, "", "end", fmt.Sprint(jobData.Search("status").Data()))
+			return false
+		}
+		if time.Now().After(deadline) {
+			// The client can reconnect with the last counter
+			writeSSE(w, "", "timeout", strconv.Itoa(counter))
+			return false
+		}
+		time.Sleep(jobStreamPollInterval)
+		return true
+	})
+}
+
+// checkJobOwner returns the job, if it was launched by the user.
+// Otherwise errJobPermission is returned.
+func checkJobOwner(job string, username string) (*gabs.Container, error) {
+	jobData, err := getJob(job)
+	if err == errJobNotFound {
+		// Don't show which jobs exist
+		log.Printf("Username %v tried to access job %v, which doesn't exist", username, job)
+		return nil, errJobPermission
+	}
+	if err != nil {
+		return nil, err
+	}
+	extraVars, err := getJobExtraVars(jobData)
+	if err != nil {
+		return nil, err
+	}
+	owner, _ := extraVars.Search("custom_tower_user_name").Data().(string)
+	if owner == "" || owner != username {
+		log.Printf("Username %v tried to access job %v of %v", username, job, owner)
+		return nil, errJobPermission
+	}
+	return jobData, nil
+}
+
+// checkJobPermissions returns the job, if it was launched through the SSP by the user
+// (custom_tower_user_name or the skip tag ssp_filter_<username>) or if the user is a
+// member of tower.job_admin_groups. Otherwise errJobPermission is returned.
+func checkJobPermissions(job string, username string) (*gabs.Container, error) {
+	return checkTowerJobPermissions("jobs", job, username)
+}
+
+// checkTowerJobPermissions is checkJobPermissions for jobs and workflow_jobs
+func checkTowerJobPermissions(path string, job string, username string) (*gabs.Container, error) {
+	jobData, err := getTowerJob(path, job)
+	if err == errJobNotFound {
+		// Don't show which jobs exist
+		log.Printf("Username %v tried to access job %v, which doesn't exist", username, job)
+		return nil, errJobPermission
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isJobLaunchedBy(jobData, username) {
+		return jobData, nil
+	}
+	adminGroups := config.Config().GetStringSlice("tower.job_admin_groups")
+	if len(adminGroups) > 0 && username != "" {
+		groups, err := getUserGroups(username)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range adminGroups {
+			if common.ContainsStringI(groups, g) {
+				return jobData, nil
+			}
+		}
+	}
+	log.Printf("Username %v tried to access job %v", username, job)
+	return nil, errJobPermission
+}
+
+// isJobLaunchedBy checks the custom_tower_user_name and the skip tag, which are set
+// by the SSP when launching a job
+func isJobLaunchedBy(job *gabs.Container, username string) bool {
+	if username == "" {
+		return false
+	}
+	if extraVars, err := getJobExtraVars(job); err == nil {
+		if owner, _ := extraVars.Search("custom_tower_user_name").Data().(string); owner == username {
+			return true
+		}
+	}
+	skipTags, _ := job.Search("skip_tags").Data().(string)
+	for _, tag := range strings.Split(skipTags, ",") {
+		if strings.TrimSpace(tag) == "ssp_filter_"+username {
+			return true
+		}
+	}
+	return false
+}
+
+func getJob(job string) (*gabs.Container, error) {
+	return getTowerJob("jobs", job)
+}
+
+func getTowerJob(path string, job string) (*gabs.Container, error) {
+	if _, err := strconv.Atoi(job); err != nil {
+		return nil, fmt.Errorf("Invalid job id: %v", job)
+	}
+	resp, err := getTowerHTTPClient("GET", path+"/"+job+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errJobNotFound
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error getting job %v. Tower returned status %v", job, resp.StatusCode)
+	}
+	return gabs.ParseJSON(body)
+}
+
+// getJobExtraVars parses the extra_vars of the job, which Tower returns as escaped json string
+func getJobExtraVars(job *gabs.Container) (*gabs.Container, error) {
+	s, ok := job.Search("extra_vars").Data().(string)
+	if !ok || s == "" {
+		return gabs.New(), nil
+	}
+	return gabs.ParseJSON([]byte(s))
+}
+
+func isJobFinished(job *gabs.Container) bool {
+	if job.Search("finished").Data() == nil {
+		return false
+	}
+	// Tower saves the events asynchronously
+	processed, ok := job.Search("event_processing_finished").Data().(bool)
+	return !ok || processed
+}
+
+type jobEvent struct {
+	counter int
+	stdout  string
+}
+
+// getJobEvents returns the job events with stdout after the counter
+func getJobEvents(job string, counter int) ([]jobEvent, error) {
+	url := fmt.Sprintf("jobs/%v/job_events/?order_by=counter&counter__gt=%v&page_size=%v", job, counter, jobEventsPageSize)
+	resp, err := getTowerHTTPClient("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error getting events of job %v. Tower returned status %v", job, resp.StatusCode)
+	}
+	json, err := gabs.ParseJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	var events []jobEvent
+	for _, e := range json.S("results").Children() {
+		c, _ := e.S("counter").Data().(float64)
+		stdout, _ := e.S("stdout").Data().(string)
+		// Events without stdout are sent too, so the counter is correct after a reconnect
+		events = append(events, jobEvent{counter: int(c), stdout: stdout})
+	}
+	return events, nil
+}
+
+// writeSSE writes a server-sent event. Every line of data is a data field.
+func writeSSE(w io.Writer, id string, event string, data string) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %v\n", id)
+	}
+	fmt.Fprintf(w, "event: %v\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %v\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
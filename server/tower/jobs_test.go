@@ -0,0 +1,185 @@
+package tower
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/testutil"
+)
+
+// newFakeTower starts a Tower API, which returns the responses by path and query
+func newFakeTower(t *testing.T, responses map[string]string) *httptest.Server {
+	server := testutil.NewFakeAPI(responses)
+	config.Init("bla")
+	config.Config().Set("tower.base_url", server.URL+"/api/v2/")
+	config.Config().Set("tower.username", "user")
+	config.Config().Set("tower.password", "pass")
+	return server
+}
+
+func TestCheckJobOwner(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/jobs/1/": `{"id": 1, "extra_vars": "{\"custom_tower_user_name\": \"u123456\"}"}`,
+		"/api/v2/jobs/2/": `{"id": 2, "extra_vars": "{}"}`,
+	})
+	defer tower.Close()
+
+	var testsets = []struct {
+		job       string
+		username  string
+		expectErr bool
+		// Only ownership failures are errJobPermission (403)
+		permissionErr bool
+	}{
+		{"1", "u123456", false, false},
+		{"1", "u654321", true, true},
+		{"2", "u123456", true, true},
+		{"3", "u123456", true, true},
+		{"1/../2", "u123456", true, false},
+	}
+	for _, tt := range testsets {
+		_, err := checkJobOwner(tt.job, tt.username)
+		if (err != nil) != tt.expectErr || (err == errJobPermission) != tt.permissionErr {
+			t.Errorf("ERROR! job %v, user %v: expected error: %v (permission: %v), got: %v", tt.job, tt.username, tt.expectErr, tt.permissionErr, err)
+		}
+	}
+}
+
+func TestCheckJobPermissions(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/jobs/1/": `{"id": 1, "extra_vars": "{\"custom_tower_user_name\": \"u123456\"}"}`,
+		"/api/v2/jobs/2/": `{"id": 2, "extra_vars": "{}", "skip_tags": "ssp_filter_u123456"}`,
+		"/api/v2/jobs/3/": `{"id": 3, "extra_vars": "{}", "skip_tags": "cleanup, ssp_filter_u123456"}`,
+		"/api/v2/jobs/4/": `{"id": 4, "extra_vars": "{}", "skip_tags": "ssp_filter_u1234567"}`,
+	})
+	defer tower.Close()
+	config.Config().Set("tower.job_admin_groups", []string{"TOWER_ADMINS"})
+
+	getUserGroups = func(username string) ([]string, error) {
+		if username == "admin" {
+			return []string{"tower_admins"}, nil
+		}
+		return []string{"OTHER_GROUP"}, nil
+	}
+	defer func() { getUserGroups = ldap.GetGroups }()
+
+	var testsets = []struct {
+		job         string
+		username    string
+		expectedErr error
+	}{
+		{"1", "u123456", nil},
+		{"1", "u654321", errJobPermission},
+		{"1", "", errJobPermission},
+		{"2", "u123456", nil},
+		{"3", "u123456", nil},
+		{"4", "u123456", errJobPermission},
+		{"4", "admin", nil},
+		// unknown jobs are not distinguishable from forbidden jobs
+		{"5", "u123456", errJobPermission},
+		{"5", "admin", errJobPermission},
+	}
+	for _, tt := range testsets {
+		_, err := checkJobPermissions(tt.job, tt.username)
+		if err != tt.expectedErr {
+			t.Errorf("ERROR! job %v, user %v: expected error: %v, got: %v", tt.job, tt.username, tt.expectedErr, err)
+		}
+	}
+}
+
+func TestGetJobOutput(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/jobs/1/":                    `{"id": 1, "extra_vars": "{\"custom_tower_user_name\": \"u123456\"}"}`,
+		"/api/v2/jobs/1/stdout/?format=html": `<pre>PLAY [all]</pre>`,
+	})
+	defer tower.Close()
+
+	output, err := getJobOutput("1", "u123456")
+	if err != nil || output != "<pre>PLAY [all]</pre>" {
+		t.Errorf("ERROR! expected output, got %v (%v)", output, err)
+	}
+	output, err = getJobOutput("1", "u654321")
+	if err != errJobPermission || output != "" {
+		t.Errorf("ERROR! expected errJobPermission, got %v (%v)", output, err)
+	}
+}
+
+func TestLaunchWorkflowTemplate(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/workflow_job_templates/22222/":             `{"id": 22222, "survey_enabled": true}`,
+		"/api/v2/workflow_job_templates/22222/survey_spec/": `{}`,
+		"/api/v2/workflow_job_templates/22222/launch/":      `{"workflow_job": 42}`,
+	})
+	defer tower.Close()
+	config.Config().Set("tower.workflow_job_templates", []map[string]interface{}{
+		{"id": "22222", "limits": []string{"webservers"}},
+	})
+
+	job, err := launchTemplate(workflowJobTemplateType, "22222", parseLaunchRequest(t, `{"extra_vars": {}, "limit": "webservers"}`), "u123456")
+	if err != nil || job != `{"workflow_job": 42}` {
+		t.Errorf("ERROR! expected workflow job, got %v (%v)", job, err)
+	}
+	_, err = launchTemplate(workflowJobTemplateType, "22222", parseLaunchRequest(t, `{"extra_vars": {}, "limit": "all"}`), "u123456")
+	if err == nil {
+		t.Errorf("ERROR! expected error for limit all")
+	}
+	_, err = launchTemplate(jobTemplateType, "22222", parseLaunchRequest(t, `{"extra_vars": {}}`), "u123456")
+	if err == nil {
+		t.Errorf("ERROR! expected error for job template, which is not whitelisted")
+	}
+}
+
+func TestGetJobEvents(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/jobs/1/job_events/?order_by=counter&counter__gt=0&page_size=200": `{"results": [
+			{"counter": 1, "stdout": "PLAY [all]"},
+			{"counter": 2, "stdout": ""},
+			{"counter": 3, "stdout": "ok: [host]"}
+		]}`,
+		"/api/v2/jobs/1/job_events/?order_by=counter&counter__gt=3&page_size=200": `{"results": []}`,
+	})
+	defer tower.Close()
+
+	events, err := getJobEvents("1", 0)
+	if err != nil {
+		t.Fatalf("ERROR! unexpected error: %v", err)
+	}
+	expected := []jobEvent{{1, "PLAY [all]"}, {2, ""}, {3, "ok: [host]"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("ERROR! expected %v, got %v", expected, events)
+	}
+	events, err = getJobEvents("1", 3)
+	if err != nil || len(events) != 0 {
+		t.Errorf("ERROR! expected no events, got %v (%v)", events, err)
+	}
+}
+
+func TestIsJobFinished(t *testing.T) {
+	var testsets = []struct {
+		job      string
+		expected bool
+	}{
+		{`{"finished": null}`, false},
+		{`{"finished": "2020-08-01T10:00:00Z", "event_processing_finished": false}`, false},
+		{`{"finished": "2020-08-01T10:00:00Z", "event_processing_finished": true}`, true},
+		{`{"finished": "2020-08-01T10:00:00Z"}`, true},
+	}
+	for _, tt := range testsets {
+		if isJobFinished(parseLaunchRequest(t, tt.job)) != tt.expected {
+			t.Errorf("ERROR! job %v: expected %v", tt.job, tt.expected)
+		}
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	var b bytes.Buffer
+	writeSSE(&b, "5", "stdout", "line 1\nline 2")
+	expected := "id: 5\nevent: stdout\ndata: line 1\ndata: line 2\n\n"
+	if b.String() != expected {
+		t.Errorf("ERROR! expected %q, got %q", expected, b.String())
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/Jeffail/gabs/v2"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
 	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
-	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/otc"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
@@ -22,15 +21,44 @@ const (
 	genericAPIError    = "Fehler beim Aufruf der Ansible Tower API. Bitte erstelle ein Ticket"
 )
 
+// templateType is a kind of template, that can be launched in Tower
+type templateType struct {
+	// Whitelist in the config file (see sample config)
+	configKey string
+	// Path in the Tower API
+	path string
+	// Workflow job templates don't support skip tags
+	skipTags bool
+}
+
+var (
+	jobTemplateType         = templateType{configKey: "tower.job_templates", path: "job_templates", skipTags: true}
+	workflowJobTemplateType = templateType{configKey: "tower.workflow_job_templates", path: "workflow_job_templates"}
+)
+
 func RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/tower/jobs/:job/stdout", getJobOutputHandler)
+	r.GET("/tower/jobs/:job/stdout/stream", streamJobOutputHandler)
+	r.POST("/tower/jobs/:job/cancel", cancelJobHandler)
+	r.POST("/tower/jobs/:job/relaunch", relaunchJobHandler)
 	r.GET("/tower/jobs/:job", getJobHandler)
 	r.GET("/tower/jobs", getJobsHandler)
+	r.GET("/tower/workflow_jobs/:job", getWorkflowJobHandler)
 	r.GET("/tower/job_templates/:jobTemplate/getDetails", getJobTemplateGetDetailsHandler)
 	r.POST("/tower/job_templates/:jobTemplate/launch", postJobTemplateLaunchHandler)
+	r.GET("/tower/workflow_job_templates/:jobTemplate/getDetails", getWorkflowJobTemplateGetDetailsHandler)
+	r.POST("/tower/workflow_job_templates/:jobTemplate/launch", postWorkflowJobTemplateLaunchHandler)
 }
 
 func postJobTemplateLaunchHandler(c *gin.Context) {
+	launchTemplateHandler(c, jobTemplateType)
+}
+
+func postWorkflowJobTemplateLaunchHandler(c *gin.Context) {
+	launchTemplateHandler(c, workflowJobTemplateType)
+}
+
+func launchTemplateHandler(c *gin.Context, t templateType) {
 	username := common.GetUserName(c)
 	jobTemplate := c.Param("jobTemplate")
 
@@ -46,7 +74,17 @@ func postJobTemplateLaunchHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
 		return
 	}
-	job, err := launchJobTemplate(jobTemplate, json, username)
+	job, err := launchTemplate(t, jobTemplate, json, username)
+	var surveyErr surveyValidationError
+	if errors.As(err, &surveyErr) {
+		log.WithFields(log.Fields{
+			"jobTemplate": jobTemplate,
+			"username":    username,
+			"errors":      surveyErr.errors,
+		}).Warn("Invalid extra_vars")
+		c.JSON(http.StatusBadRequest, SurveyValidationResponse{Message: surveyValidationErrorMessage, Errors: surveyErr.errors})
+		return
+	}
 	if err != nil {
 		log.Errorf("%v", err)
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
@@ -55,16 +93,30 @@ func postJobTemplateLaunchHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
-func launchJobTemplate(jobTemplate string, json *gabs.Container, username string) (string, error) {
+func launchTemplate(t templateType, jobTemplate string, json *gabs.Container, username string) (string, error) {
 	// Check if the user is allowed to execute this jobTemplate.
 	// This also checks if the jobTemplate is whitelisted (see sample config)
-	if err := checkPermissions(jobTemplate, json, username); err != nil {
+	if err := checkTemplatePermissions(t, jobTemplate, json, username); err != nil {
 		return "", err
 	}
 
 	// Remove extra_vars that the user is not allowed to set.
 	json = removeBlacklistedParameters(json)
 
+	// Validate the extra_vars against the survey of the job template
+	template, err := getTowerJob(t.path, jobTemplate)
+	if err != nil {
+		return "", err
+	}
+	surveyEnabled, _ := template.Search("survey_enabled").Data().(bool)
+	spec, err := getSurveySpec(t, jobTemplate)
+	if err != nil {
+		return "", err
+	}
+	if errs := validateSurvey(surveyEnabled, spec, json); len(errs) > 0 {
+		return "", surveyValidationError{errs}
+	}
+
 	// Overwrite/set the username, this is mostly used for email notifications and
 	// for filtering jobs in the SSP (list all jobs with one username)
 	json.SetP(username, "extra_vars.custom_tower_user_name")
@@ -75,9 +127,11 @@ func launchJobTemplate(jobTemplate string, json *gabs.Container, username string
 	// but since there is none, it is ignored.
 	// We need this because filtering on extra_vars is not possible
 	// and artifacts only appear when the job is done.
-	json.SetP("ssp_filter_"+username, "skip_tags")
+	if t.skipTags {
+		json.SetP("ssp_filter_"+username, "skip_tags")
+	}
 
-	resp, err := getTowerHTTPClient("POST", "job_templates/"+jobTemplate+"/launch/", bytes.NewReader(json.Bytes()))
+	resp, err := getTowerHTTPClient("POST", t.path+"/"+jobTemplate+"/launch/", bytes.NewReader(json.Bytes()))
 
 	if err != nil {
 		return "", err
@@ -103,10 +157,18 @@ func launchJobTemplate(jobTemplate string, json *gabs.Container, username string
 }
 
 func getJobTemplateGetDetailsHandler(c *gin.Context) {
+	getTemplateDetailsHandler(c, jobTemplateType)
+}
+
+func getWorkflowJobTemplateGetDetailsHandler(c *gin.Context) {
+	getTemplateDetailsHandler(c, workflowJobTemplateType)
+}
+
+func getTemplateDetailsHandler(c *gin.Context, t templateType) {
 	username := common.GetUserName(c)
 	jobTemplate := c.Param("jobTemplate")
 
-	details, err := getJobTemplateDetails(jobTemplate, username)
+	details, err := getTemplateDetails(t, jobTemplate, username)
 	if err != nil {
 		log.Errorf("%v", err)
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
@@ -115,28 +177,14 @@ func getJobTemplateGetDetailsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, details)
 }
 
-func getJobTemplateDetails(jobTemplate string, username string) (string, error) {
+func getTemplateDetails(t templateType, jobTemplate string, username string) (string, error) {
 	// Check if the user is allowed to execute this jobTemplate.
 	// This also checks if the jobTemplate is whitelisted (see sample config)
-	if err := checkPermissions(jobTemplate, nil, username); err != nil {
+	if err := checkTemplatePermissions(t, jobTemplate, nil, username); err != nil {
 		return "", err
 	}
 
-	resp, err := getTowerHTTPClient("GET", "job_templates/"+jobTemplate+"/survey_spec/", nil)
-
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	if resp.StatusCode == http.StatusBadRequest {
-		// Should never happen
-		return "", fmt.Errorf("Error from Ansible Tower")
-	}
-	details, err := gabs.ParseJSON(body)
+	details, err := getSurveySpec(t, jobTemplate)
 	if err != nil {
 		return "", err
 	}
@@ -149,6 +197,23 @@ func getJobTemplateDetails(jobTemplate string, username string) (string, error)
 	return details.String(), nil
 }
 
+func getSurveySpec(t templateType, jobTemplate string) (*gabs.Container, error) {
+	resp, err := getTowerHTTPClient("GET", t.path+"/"+jobTemplate+"/survey_spec/", nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error getting the survey of %v. Tower returned status %v", jobTemplate, resp.StatusCode)
+	}
+	return gabs.ParseJSON(body)
+}
+
 func addSpecsMap(details *gabs.Container) error {
 	// rearranging specs as a hashmap for better navigation in frontend
 	for index, spec := range details.Path("spec").Children() {
@@ -165,8 +230,14 @@ func addSpecsMap(details *gabs.Container) error {
 }
 
 type jobTemplateConfig struct {
-	ID       string
-	Validate string
+	ID string
+	// Deprecated: use validators
+	Validate   string
+	Validators []validatorConfig
+	// Allowed values for limit and inventory of the launch request.
+	// If not set, the user cannot pass them.
+	Limits      []string
+	Inventories []string
 }
 
 func removeBlacklistedParameters(json *gabs.Container) *gabs.Container {
@@ -187,10 +258,14 @@ func removeBlacklistedParameters(json *gabs.Container) *gabs.Container {
 }
 
 func checkPermissions(jobTemplate string, json *gabs.Container, username string) error {
+	return checkTemplatePermissions(jobTemplateType, jobTemplate, json, username)
+}
+
+func checkTemplatePermissions(tt templateType, jobTemplate string, json *gabs.Container, username string) error {
 	cfg := config.Config()
 
 	jobTemplateConfigs := []jobTemplateConfig{}
-	if err := cfg.UnmarshalKey("tower.job_templates", &jobTemplateConfigs); err != nil {
+	if err := cfg.UnmarshalKey(tt.configKey, &jobTemplateConfigs); err != nil {
 		return err
 	}
 	// Check if the template id is whitelisted in the config file (see sample config)
@@ -201,156 +276,130 @@ func checkPermissions(jobTemplate string, json *gabs.Container, username string)
 		// This is an optional setting in the configfile (see sample config)
 		// It means that additional checks are needed. This is mostly done
 		// by calling an external service/package.
-		if t.Validate != "" {
-			if err := checkServicePermissions(t, json, username); err != nil {
+		templateValidators, err := getValidators(t)
+		if err != nil {
+			return err
+		}
+		for _, v := range templateValidators {
+			if err := v.validate(json, username); err != nil {
 				return err
 			}
 		}
+		if err := checkLaunchOptions(t, json); err != nil {
+			return err
+		}
 		log.Printf("Job template %v allowed for %v", jobTemplate, username)
 		return nil
 	}
-	return fmt.Errorf("Username %v tried to launch job template %v. Not in allowed job_templates", username, jobTemplate)
+	return fmt.Errorf("Username %v tried to launch %v %v. Not in allowed %v", username, tt.path, jobTemplate, tt.configKey)
 }
 
-// This function is only executed if "validate" is specified in the configfile
-// There can be multiple validations (see below), if the specified validation
-// doesn't exist in the below code, then the check will fail.
-func checkServicePermissions(template jobTemplateConfig, json *gabs.Container, username string) error {
-	// Validate the uos_group metadata on the server, that is being modified/deleted.
-	// Permission only has to be checked if the server already exists.
-	if template.Validate == "metadata.uos_group" {
-		// To check the "metadata.uos_group" field we need to get the server from OTC
-		// We mostly do this by hostname, because the ID is not human readable and
-		// this data mostly comes from the Tower Survey or SSP.
-		//
-		// **Notes for future contributors:**
-		// At the moment the tenant is evaluated with the hostname and there is only the managed project.
-		// When there are more tenants/projects it might be necessary to somehow evaluate
-		// which tenant/project the server hostname belongs to. This could be achieved by parsing the
-		// job templates name (from Tower), if these are consistent. Another possibility would be to
-		// add tenant and project fields to every job_template in the config file (see jobTemplateConfig struct).
-		servername := json.Path("extra_vars.unifiedos_hostname").Data().(string)
-		// this function gets the server data and validates the groups of username against the metadata
-		if err := otc.ValidatePermissionsByHostname(servername, username); err != nil {
-			return err
-		}
-		// If there is no error, then the user has permission
+// checkLaunchOptions checks the limit and inventory of the launch request against the
+// allowed values of the job template
+func checkLaunchOptions(t jobTemplateConfig, json *gabs.Container) error {
+	if json == nil {
 		return nil
 	}
-	// Fail if the validation is not defined above or there is a typo in the configuration
-	return fmt.Errorf("No existing validation matches: %v Check the configuration", template.Validate)
+	options := []struct {
+		name    string
+		allowed []string
+	}{
+		{"limit", t.Limits},
+		{"inventory", t.Inventories},
+	}
+	for _, o := range options {
+		value := json.Search(o.name).Data()
+		if value == nil || value == "" {
+			continue
+		}
+		// Inventories are ids
+		if !contains(o.allowed, fmt.Sprint(value)) {
+			return fmt.Errorf("Value %v of %v is not allowed for job template %v. Allowed values: %v", value, o.name, t.ID, strings.Join(o.allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// getValidators returns the validators of the job template. All of them must succeed.
+// If a validator doesn't exist, the check fails.
+func getValidators(template jobTemplateConfig) ([]validator, error) {
+	configs := template.Validators
+	if template.Validate != "" {
+		// Old syntax: validate: metadata.uos_group
+		if template.Validate != "metadata.uos_group" {
+			return nil, fmt.Errorf("No existing validation matches: %v Check the configuration", template.Validate)
+		}
+		configs = append([]validatorConfig{{Name: "uos_group"}}, configs...)
+	}
+	var templateValidators []validator
+	for _, cfg := range configs {
+		v, err := newValidator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		templateValidators = append(templateValidators, v)
+	}
+	return templateValidators, nil
 }
 
 func getJobOutputHandler(c *gin.Context) {
+	username := common.GetUserName(c)
 	job := c.Param("job")
-	resp, err := getTowerHTTPClient("GET", "jobs/"+job+"/stdout/?format=html", nil)
-	if err != nil {
-		log.Errorf("%v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+
+	output, err := getJobOutput(job, username)
+	if err == errJobPermission {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
 		return
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Errorf("%v", err)
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
 		return
 	}
 
-	c.JSON(http.StatusOK, string(body))
+	c.JSON(http.StatusOK, output)
 }
 
-func getJobHandler(c *gin.Context) {
-	job := c.Param("job")
-	resp, err := getTowerHTTPClient("GET", "jobs/"+job, nil)
+func getJobOutput(job string, username string) (string, error) {
+	if _, err := checkJobPermissions(job, username); err != nil {
+		return "", err
+	}
+	resp, err := getTowerHTTPClient("GET", "jobs/"+job+"/stdout/?format=html", nil)
 	if err != nil {
-		log.Errorf("%v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
-		return
+		return "", err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Errorf("%v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
-		return
+		return "", err
 	}
+	return string(body), nil
+}
+
+func getJobHandler(c *gin.Context) {
+	getTowerJobHandler(c, "jobs")
+}
 
-	c.JSON(http.StatusOK, string(body))
+func getWorkflowJobHandler(c *gin.Context) {
+	getTowerJobHandler(c, "workflow_jobs")
 }
 
-func getJobsHandler(c *gin.Context) {
+func getTowerJobHandler(c *gin.Context, path string) {
 	username := common.GetUserName(c)
-	// We need to first get the finished jobs and then the failed/running jobs, because the Tower-API
-	// doesn't allow filtering by extra_vars (as far as I know).
-	finishedJobs, err := getFinishedJobs(username)
-	if err != nil {
-		log.Errorf("%v", err)
-		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
+	job := c.Param("job")
+
+	jobData, err := checkTowerJobPermissions(path, job, username)
+	if err == errJobPermission {
+		c.JSON(http.StatusForbidden, common.ApiResponse{Message: jobPermissionError})
 		return
 	}
-	failedOrRunningJobs, err := getFailedOrRunningJobs(username)
 	if err != nil {
 		log.Errorf("%v", err)
 		c.JSON(http.StatusBadRequest, common.ApiResponse{Message: genericAPIError})
 		return
 	}
-	finishedJobs.Merge(failedOrRunningJobs)
-
-	c.JSON(http.StatusOK, finishedJobs.S("results").String())
-}
-
-// TODO: wait a few weeks, switch to skip tag filtering and remove this code
-func getFinishedJobs(username string) (*gabs.Container, error) {
-	// Get all the jobs that have artifacts which contain the username. This could produce a few
-	// false-positives in the future.
-	resp, err := getTowerHTTPClient("GET", "jobs/?order_by=-created&artifacts__contains="+username, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return gabs.ParseJSON(body)
-}
 
-// TODO: wait a few weeks, switch to skip tag filtering and remove this code
-func getFailedOrRunningJobs(username string) (*gabs.Container, error) {
-	// Get all the failed/running jobs (of all users, because we cannot filter by extra_vars
-	// and artifacts are not available yet) and then loop through and only keep
-	// if custom_tower_user_name is set.
-	resp, err := getTowerHTTPClient("GET", "jobs/?order_by=-created&or__status=failed&or__finished__isnull=true", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	jobs, err := gabs.ParseJSON(body)
-	if err != nil {
-		return nil, err
-	}
-	jsonObj := gabs.New()
-	// Ugly hack to filter on extra_vars.custom_tower_user_name
-	// Because the tower api doesn't allow filtering on custom_vars
-	// custom_vars is an escaped json string
-	for _, job := range jobs.S("results").Children() {
-		extra_vars, err := gabs.ParseJSON([]byte(job.S("extra_vars").Data().(string)))
-		if err != nil {
-			log.Error(err)
-			continue
-		}
-		// Can be nil, if the value doesn't exist
-		ctun := extra_vars.S("custom_tower_user_name").Data()
-		if ctun != nil && ctun.(string) == username {
-			jsonObj.ArrayAppend(job.Data(), "results")
-		}
-	}
-	return jsonObj, nil
+	c.JSON(http.StatusOK, jobData.String())
 }
 
 func getTowerHTTPClient(method string, urlPart string, body io.Reader) (*http.Response, error) {
@@ -178,3 +178,18 @@ func TestAddSpecsMap(t *testing.T) {
 		t.Error("ERROR! detailsWithMap is not as expected...")
 	}
 }
+
+func TestGetSurveySpec(t *testing.T) {
+	tower := newFakeTower(t, map[string]string{
+		"/api/v2/job_templates/11111/survey_spec/": `{"spec": []}`,
+	})
+	defer tower.Close()
+
+	if _, err := getSurveySpec(jobTemplateType, "11111"); err != nil {
+		t.Errorf("ERROR! expected survey spec, got %v", err)
+	}
+	// Every status except 200 is an error, e.g. 404 or 403 from Tower
+	if spec, err := getSurveySpec(jobTemplateType, "99999"); err == nil {
+		t.Errorf("ERROR! expected error for missing template, got %v", spec)
+	}
+}
@@ -0,0 +1,178 @@
+package tower
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+const surveyValidationErrorMessage = "Ungültige Parameter: Bitte überprüfe die markierten Felder"
+
+type SurveyError struct {
+	Variable string `json:"variable"`
+	Message  string `json:"message"`
+}
+
+type SurveyValidationResponse struct {
+	Message string        `json:"message"`
+	Errors  []SurveyError `json:"errors"`
+}
+
+// surveyValidationError is returned, if the extra_vars don't match the survey
+type surveyValidationError struct {
+	errors []SurveyError
+}
+
+func (e surveyValidationError) Error() string {
+	var msgs []string
+	for _, err := range e.errors {
+		msgs = append(msgs, err.Variable+": "+err.Message)
+	}
+	return "Invalid extra_vars: " + strings.Join(msgs, ", ")
+}
+
+// validateSurvey validates the extra_vars of the launch request against the survey spec
+// (required, type, min/max and choices). Variables which are not in the survey are rejected.
+// If the survey is disabled or the job template has no survey, nothing is validated.
+func validateSurvey(surveyEnabled bool, spec *gabs.Container, json *gabs.Container) []SurveyError {
+	// Tower ignores the spec of a disabled survey
+	if !surveyEnabled {
+		return nil
+	}
+	questions := spec.Path("spec").Children()
+	if len(questions) == 0 {
+		return nil
+	}
+	extraVars := json.Search("extra_vars").ChildrenMap()
+
+	var errs []SurveyError
+	known := make(map[string]bool)
+	for _, q := range questions {
+		variable, _ := q.Search("variable").Data().(string)
+		known[variable] = true
+		if msg := validateSurveyAnswer(q, extraVars[variable]); msg != "" {
+			errs = append(errs, SurveyError{Variable: variable, Message: msg})
+		}
+	}
+
+	var unknown []string
+	for variable := range extraVars {
+		if !known[variable] {
+			unknown = append(unknown, variable)
+		}
+	}
+	// map order is random
+	sort.Strings(unknown)
+	for _, variable := range unknown {
+		errs = append(errs, SurveyError{Variable: variable, Message: "Unbekannter Parameter"})
+	}
+	return errs
+}
+
+// validateSurveyAnswer returns an error message, if the value doesn't match the question
+func validateSurveyAnswer(q *gabs.Container, answer *gabs.Container) string {
+	var value interface{}
+	if answer != nil {
+		value = answer.Data()
+	}
+	if value == nil || value == "" {
+		if required, _ := q.Search("required").Data().(bool); required {
+			return "Pflichtfeld"
+		}
+		return ""
+	}
+
+	min, hasMin := toFloat(q.Search("min").Data())
+	max, hasMax := toFloat(q.Search("max").Data())
+	questionType, _ := q.Search("type").Data().(string)
+	switch questionType {
+	case "text", "textarea", "password":
+		s, ok := value.(string)
+		if !ok {
+			return "Muss ein Text sein"
+		}
+		length := float64(utf8.RuneCountInString(s))
+		if hasMin && length < min {
+			return fmt.Sprintf("Muss mindestens %v Zeichen lang sein", min)
+		}
+		if hasMax && length > max {
+			return fmt.Sprintf("Darf höchstens %v Zeichen lang sein", max)
+		}
+	case "integer", "float":
+		n, ok := value.(float64)
+		if !ok {
+			return "Muss eine Zahl sein"
+		}
+		if questionType == "integer" && n != math.Trunc(n) {
+			return "Muss eine Ganzzahl sein"
+		}
+		if hasMin && n < min {
+			return fmt.Sprintf("Muss mindestens %v sein", min)
+		}
+		if hasMax && n > max {
+			return fmt.Sprintf("Darf höchstens %v sein", max)
+		}
+	case "multiplechoice":
+		s, ok := value.(string)
+		if !ok || !contains(getChoices(q), s) {
+			return fmt.Sprintf("Ungültiger Wert. Erlaubt: %v", strings.Join(getChoices(q), ", "))
+		}
+	case "multiselect":
+		// Tower accepts a list or a newline separated string
+		var selected []string
+		switch v := value.(type) {
+		case string:
+			selected = strings.Split(v, "\n")
+		case []interface{}:
+			for _, s := range v {
+				selected = append(selected, fmt.Sprint(s))
+			}
+		default:
+			return "Muss eine Liste sein"
+		}
+		choices := getChoices(q)
+		for _, s := range selected {
+			if !contains(choices, s) {
+				return fmt.Sprintf("Ungültiger Wert: %v. Erlaubt: %v", s, strings.Join(choices, ", "))
+			}
+		}
+	}
+	return ""
+}
+
+// getChoices returns the choices of a question. Tower stores them as
+// newline separated string or as list.
+func getChoices(q *gabs.Container) []string {
+	var choices []string
+	switch c := q.Search("choices").Data().(type) {
+	case string:
+		for _, s := range strings.Split(c, "\n") {
+			if s != "" {
+				choices = append(choices, s)
+			}
+		}
+	case []interface{}:
+		for _, s := range c {
+			choices = append(choices, fmt.Sprint(s))
+		}
+	}
+	return choices
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package tower
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateSurvey(t *testing.T) {
+	spec := parseLaunchRequest(t, `{
+      "spec": [
+        {"variable": "hostname", "type": "text", "required": true, "min": 3, "max": 10, "choices": ""},
+        {"variable": "disk_size", "type": "integer", "required": true, "min": 10, "max": 500, "choices": ""},
+        {"variable": "ratio", "type": "float", "required": false, "min": null, "max": 1, "choices": ""},
+        {"variable": "image", "type": "multiplechoice", "required": false, "min": null, "max": null, "choices": "rhel7\nwindows2016"},
+        {"variable": "features", "type": "multiselect", "required": false, "min": null, "max": null, "choices": ["backup", "monitoring"]}
+      ]
+    }`)
+
+	var testsets = []struct {
+		request  string
+		expected []SurveyError
+	}{
+		{`{"extra_vars": {"hostname": "xyz01", "disk_size": 20}}`, nil},
+		{`{"extra_vars": {"hostname": "xyz01", "disk_size": 20, "ratio": 0.5, "image": "rhel7", "features": ["backup"]}}`, nil},
+		{`{"extra_vars": {"hostname": "xyz01", "disk_size": 20, "features": "backup\nmonitoring"}}`, nil},
+		{`{"extra_vars": {}}`, []SurveyError{
+			{"hostname", "Pflichtfeld"},
+			{"disk_size", "Pflichtfeld"},
+		}},
+		{`{}`, []SurveyError{
+			{"hostname", "Pflichtfeld"},
+			{"disk_size", "Pflichtfeld"},
+		}},
+		{`{"extra_vars": {"hostname": "xy", "disk_size": 20.5}}`, []SurveyError{
+			{"hostname", "Muss mindestens 3 Zeichen lang sein"},
+			{"disk_size", "Muss eine Ganzzahl sein"},
+		}},
+		{`{"extra_vars": {"hostname": "xyz01xyz01xyz01", "disk_size": 1000}}`, []SurveyError{
+			{"hostname", "Darf höchstens 10 Zeichen lang sein"},
+			{"disk_size", "Darf höchstens 500 sein"},
+		}},
+		{`{"extra_vars": {"hostname": 123, "disk_size": "20"}}`, []SurveyError{
+			{"hostname", "Muss ein Text sein"},
+			{"disk_size", "Muss eine Zahl sein"},
+		}},
+		{`{"extra_vars": {"hostname": "xyz01", "disk_size": 20, "ratio": 2, "image": "ubuntu", "features": ["backup", "logging"]}}`, []SurveyError{
+			{"ratio", "Darf höchstens 1 sein"},
+			{"image", "Ungültiger Wert. Erlaubt: rhel7, windows2016"},
+			{"features", "Ungültiger Wert: logging. Erlaubt: backup, monitoring"},
+		}},
+		{`{"extra_vars": {"hostname": "xyz01", "disk_size": 20, "zzz": 1, "aaa": 2}}`, []SurveyError{
+			{"aaa", "Unbekannter Parameter"},
+			{"zzz", "Unbekannter Parameter"},
+		}},
+	}
+
+	for i, tt := range testsets {
+		errs := validateSurvey(true, spec, parseLaunchRequest(t, tt.request))
+		if !reflect.DeepEqual(errs, tt.expected) {
+			t.Errorf("ERROR! testset %v: expected %v, got %v", i, tt.expected, errs)
+		}
+	}
+}
+
+func TestValidateSurveyWithoutSpec(t *testing.T) {
+	spec := parseLaunchRequest(t, `{}`)
+	if errs := validateSurvey(true, spec, parseLaunchRequest(t, `{"extra_vars": {"any": 1}}`)); errs != nil {
+		t.Errorf("ERROR! job templates without survey should not be validated, got %v", errs)
+	}
+}
+
+func TestValidateSurveyDisabled(t *testing.T) {
+	spec := parseLaunchRequest(t, `{"spec": [{"variable": "hostname", "type": "text", "required": true}]}`)
+	if errs := validateSurvey(false, spec, parseLaunchRequest(t, `{"extra_vars": {"any": 1}}`)); errs != nil {
+		t.Errorf("ERROR! disabled surveys should not be validated, got %v", errs)
+	}
+}
@@ -0,0 +1,210 @@
+package tower
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/common"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/ldap"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/openshift"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/otc"
+)
+
+// validator checks if a user is allowed to launch a job template.
+// json is the launch request with the extra_vars. It is nil, if the
+// job template is not launched (e.g. getDetails). Validators, which
+// need extra_vars, skip the check in this case.
+type validator interface {
+	validate(json *gabs.Container, username string) error
+}
+
+// validatorConfig is an entry of job_templates[].validators (see sample config).
+// The parameters depend on the validator.
+type validatorConfig struct {
+	Name            string
+	Groups          []string
+	Variable        string
+	Values          []string
+	ClusterVariable string `mapstructure:"cluster_variable"`
+	ProjectVariable string `mapstructure:"project_variable"`
+}
+
+// validators contains all the validators, that can be used in the config file.
+// To add a new validator: implement the validator interface and add it here.
+var validators = map[string]func(cfg validatorConfig) (validator, error){
+	"uos_group":               newUOSGroupValidator,
+	"ldap_group":              newLDAPGroupValidator,
+	"openshift_project_admin": newOpenshiftProjectAdminValidator,
+	"rds_group":               newRDSGroupValidator,
+	"allowed_values":          newAllowedValuesValidator,
+}
+
+func newValidator(cfg validatorConfig) (validator, error) {
+	factory, ok := validators[cfg.Name]
+	if !ok {
+		// Fail if there is a typo in the configuration
+		return nil, fmt.Errorf("No existing validation matches: %v Check the configuration", cfg.Name)
+	}
+	return factory(cfg)
+}
+
+// getExtraVar returns an extra_var of the launch request as string
+func getExtraVar(json *gabs.Container, name string) (string, error) {
+	value := json.Search("extra_vars", name).Data()
+	if value == nil {
+		return "", fmt.Errorf("Parameter %v must be included in the extra_vars", name)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// uosGroupValidator checks the metadata.uos_group of the server in the extra_var
+type uosGroupValidator struct {
+	variable            string
+	validatePermissions func(servername string, username string) error
+}
+
+func newUOSGroupValidator(cfg validatorConfig) (validator, error) {
+	variable := cfg.Variable
+	if variable == "" {
+		variable = "unifiedos_hostname"
+	}
+	return uosGroupValidator{
+		variable:            variable,
+		validatePermissions: otc.ValidatePermissionsByHostname,
+	}, nil
+}
+
+func (v uosGroupValidator) validate(json *gabs.Container, username string) error {
+	if json == nil {
+		return nil
+	}
+	servername, err := getExtraVar(json, v.variable)
+	if err != nil {
+		return err
+	}
+	// this function gets the server data and validates the groups of username against the metadata
+	return v.validatePermissions(servername, username)
+}
+
+// ldapGroupValidator checks if the user is a member of one of the groups
+type ldapGroupValidator struct {
+	groups    []string
+	getGroups func(username string) ([]string, error)
+}
+
+func newLDAPGroupValidator(cfg validatorConfig) (validator, error) {
+	if len(cfg.Groups) == 0 {
+		return nil, fmt.Errorf("Validation %v needs the parameter groups", cfg.Name)
+	}
+	return ldapGroupValidator{
+		groups:    cfg.Groups,
+		getGroups: ldap.GetGroups,
+	}, nil
+}
+
+func (v ldapGroupValidator) validate(json *gabs.Container, username string) error {
+	userGroups, err := v.getGroups(username)
+	if err != nil {
+		return err
+	}
+	for _, g := range v.groups {
+		if common.ContainsStringI(userGroups, g) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Username %v is not a member of: %v", username, strings.Join(v.groups, ", "))
+}
+
+// openshiftProjectAdminValidator checks if the user is an admin of the project in the extra_vars
+type openshiftProjectAdminValidator struct {
+	clusterVariable       string
+	projectVariable       string
+	checkAdminPermissions func(clusterId, username, project string) error
+}
+
+func newOpenshiftProjectAdminValidator(cfg validatorConfig) (validator, error) {
+	if cfg.ClusterVariable == "" || cfg.ProjectVariable == "" {
+		return nil, fmt.Errorf("Validation %v needs the parameters cluster_variable and project_variable", cfg.Name)
+	}
+	return openshiftProjectAdminValidator{
+		clusterVariable:       cfg.ClusterVariable,
+		projectVariable:       cfg.ProjectVariable,
+		checkAdminPermissions: openshift.CheckAdminPermissions,
+	}, nil
+}
+
+func (v openshiftProjectAdminValidator) validate(json *gabs.Container, username string) error {
+	if json == nil {
+		return nil
+	}
+	clusterId, err := getExtraVar(json, v.clusterVariable)
+	if err != nil {
+		return err
+	}
+	project, err := getExtraVar(json, v.projectVariable)
+	if err != nil {
+		return err
+	}
+	return v.checkAdminPermissions(clusterId, username, project)
+}
+
+// rdsGroupValidator checks the rds_group tag of the RDS instance in the extra_var
+type rdsGroupValidator struct {
+	variable               string
+	validateRDSPermissions func(id string, username string) error
+}
+
+func newRDSGroupValidator(cfg validatorConfig) (validator, error) {
+	if cfg.Variable == "" {
+		return nil, fmt.Errorf("Validation %v needs the parameter variable", cfg.Name)
+	}
+	return rdsGroupValidator{
+		variable:               cfg.Variable,
+		validateRDSPermissions: otc.ValidateRDSPermissions,
+	}, nil
+}
+
+func (v rdsGroupValidator) validate(json *gabs.Container, username string) error {
+	if json == nil {
+		return nil
+	}
+	id, err := getExtraVar(json, v.variable)
+	if err != nil {
+		return err
+	}
+	return v.validateRDSPermissions(id, username)
+}
+
+// allowedValuesValidator checks if the extra_var has one of the values.
+// If the extra_var is not set, the default of Tower is used.
+type allowedValuesValidator struct {
+	variable string
+	values   []string
+}
+
+func newAllowedValuesValidator(cfg validatorConfig) (validator, error) {
+	if cfg.Variable == "" || len(cfg.Values) == 0 {
+		return nil, fmt.Errorf("Validation %v needs the parameters variable and values", cfg.Name)
+	}
+	return allowedValuesValidator{
+		variable: cfg.Variable,
+		values:   cfg.Values,
+	}, nil
+}
+
+func (v allowedValuesValidator) validate(json *gabs.Container, username string) error {
+	if json == nil || !json.Exists("extra_vars", v.variable) {
+		return nil
+	}
+	value, err := getExtraVar(json, v.variable)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range v.values {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("Value %v of parameter %v is not allowed. Allowed values: %v", value, v.variable, strings.Join(v.values, ", "))
+}
@@ -0,0 +1,246 @@
+package tower
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/SchweizerischeBundesbahnen/ssp-backend/server/config"
+)
+
+func parseLaunchRequest(t *testing.T, s string) *gabs.Container {
+	json, err := gabs.ParseJSON([]byte(s))
+	if err != nil {
+		t.Fatalf("Invalid JSON! %v", err)
+	}
+	return json
+}
+
+func TestUOSGroupValidator(t *testing.T) {
+	v := uosGroupValidator{
+		variable: "unifiedos_hostname",
+		validatePermissions: func(servername string, username string) error {
+			if servername == "xyzt01.sbb.ch" && username == "u123456" {
+				return nil
+			}
+			return fmt.Errorf("not allowed")
+		},
+	}
+	var testsets = []struct {
+		request   string
+		username  string
+		expectErr bool
+	}{
+		{`{"extra_vars": {"unifiedos_hostname": "xyzt01.sbb.ch"}}`, "u123456", false},
+		{`{"extra_vars": {"unifiedos_hostname": "xyzt01.sbb.ch"}}`, "u654321", true},
+		{`{"extra_vars": {"unifiedos_hostname": "xyzt02.sbb.ch"}}`, "u123456", true},
+		{`{"extra_vars": {}}`, "u123456", true},
+	}
+	for i, tt := range testsets {
+		err := v.validate(parseLaunchRequest(t, tt.request), tt.username)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+	if err := v.validate(nil, "u654321"); err != nil {
+		t.Errorf("ERROR! validation without extra_vars should be skipped, got: %v", err)
+	}
+}
+
+func TestLDAPGroupValidator(t *testing.T) {
+	v := ldapGroupValidator{
+		groups: []string{"DG_GROUP_A", "DG_GROUP_B"},
+		getGroups: func(username string) ([]string, error) {
+			switch username {
+			case "member":
+				return []string{"DG_OTHER", "dg_group_b"}, nil
+			case "broken":
+				return nil, fmt.Errorf("ldap error")
+			}
+			return []string{"DG_OTHER"}, nil
+		},
+	}
+	var testsets = []struct {
+		username  string
+		expectErr bool
+	}{
+		{"member", false},
+		{"other", true},
+		{"broken", true},
+	}
+	for _, tt := range testsets {
+		// the group is also checked without extra_vars
+		err := v.validate(nil, tt.username)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! username %v: expected error: %v, got: %v", tt.username, tt.expectErr, err)
+		}
+	}
+}
+
+func TestOpenshiftProjectAdminValidator(t *testing.T) {
+	v := openshiftProjectAdminValidator{
+		clusterVariable: "cluster",
+		projectVariable: "project",
+		checkAdminPermissions: func(clusterId, username, project string) error {
+			if clusterId == "awsdev" && project == "my-project" && username == "admin" {
+				return nil
+			}
+			return fmt.Errorf("not allowed")
+		},
+	}
+	var testsets = []struct {
+		request   string
+		username  string
+		expectErr bool
+	}{
+		{`{"extra_vars": {"cluster": "awsdev", "project": "my-project"}}`, "admin", false},
+		{`{"extra_vars": {"cluster": "awsdev", "project": "my-project"}}`, "other", true},
+		{`{"extra_vars": {"cluster": "awsdev", "project": "other-project"}}`, "admin", true},
+		{`{"extra_vars": {"project": "my-project"}}`, "admin", true},
+	}
+	for i, tt := range testsets {
+		err := v.validate(parseLaunchRequest(t, tt.request), tt.username)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestRDSGroupValidator(t *testing.T) {
+	v := rdsGroupValidator{
+		variable: "rds_instance_id",
+		validateRDSPermissions: func(id string, username string) error {
+			if id == "abc" {
+				return nil
+			}
+			return fmt.Errorf("not allowed")
+		},
+	}
+	var testsets = []struct {
+		request   string
+		expectErr bool
+	}{
+		{`{"extra_vars": {"rds_instance_id": "abc"}}`, false},
+		{`{"extra_vars": {"rds_instance_id": "def"}}`, true},
+		{`{"extra_vars": {}}`, true},
+	}
+	for i, tt := range testsets {
+		err := v.validate(parseLaunchRequest(t, tt.request), "u123456")
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestAllowedValuesValidator(t *testing.T) {
+	v := allowedValuesValidator{
+		variable: "size",
+		values:   []string{"small", "10"},
+	}
+	var testsets = []struct {
+		request   string
+		expectErr bool
+	}{
+		{`{"extra_vars": {"size": "small"}}`, false},
+		{`{"extra_vars": {"size": 10}}`, false},
+		{`{"extra_vars": {"size": "large"}}`, true},
+		{`{"extra_vars": {"size": null}}`, true},
+		{`{"extra_vars": {}}`, false},
+	}
+	for i, tt := range testsets {
+		err := v.validate(parseLaunchRequest(t, tt.request), "u123456")
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestGetValidators(t *testing.T) {
+	var testsets = []struct {
+		template      jobTemplateConfig
+		expectedCount int
+		expectErr     bool
+	}{
+		{jobTemplateConfig{ID: "1"}, 0, false},
+		{jobTemplateConfig{ID: "2", Validate: "metadata.uos_group"}, 1, false},
+		{jobTemplateConfig{ID: "3", Validate: "metadata.typo"}, 0, true},
+		{jobTemplateConfig{ID: "4", Validate: "metadata.uos_group", Validators: []validatorConfig{
+			{Name: "ldap_group", Groups: []string{"DG_GROUP_A"}},
+		}}, 2, false},
+		{jobTemplateConfig{ID: "5", Validators: []validatorConfig{
+			{Name: "allowed_values", Variable: "size", Values: []string{"small"}},
+			{Name: "rds_group", Variable: "rds_instance_id"},
+			{Name: "openshift_project_admin", ClusterVariable: "cluster", ProjectVariable: "project"},
+		}}, 3, false},
+		{jobTemplateConfig{ID: "6", Validators: []validatorConfig{{Name: "unknown"}}}, 0, true},
+		{jobTemplateConfig{ID: "7", Validators: []validatorConfig{{Name: "ldap_group"}}}, 0, true},
+		{jobTemplateConfig{ID: "8", Validators: []validatorConfig{{Name: "allowed_values", Variable: "size"}}}, 0, true},
+		{jobTemplateConfig{ID: "9", Validators: []validatorConfig{{Name: "rds_group"}}}, 0, true},
+		{jobTemplateConfig{ID: "10", Validators: []validatorConfig{{Name: "openshift_project_admin", ClusterVariable: "cluster"}}}, 0, true},
+	}
+	for _, tt := range testsets {
+		v, err := getValidators(tt.template)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! template %v: expected error: %v, got: %v", tt.template.ID, tt.expectErr, err)
+		}
+		if len(v) != tt.expectedCount {
+			t.Errorf("ERROR! template %v: expected %v validators, got %v", tt.template.ID, tt.expectedCount, len(v))
+		}
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("tower.job_templates", []map[string]interface{}{
+		{"id": "11111"},
+		{
+			"id": "12345",
+			"validators": []map[string]interface{}{
+				{"name": "allowed_values", "variable": "size", "values": []string{"small", "medium"}},
+			},
+		},
+		{
+			"id":          "23456",
+			"limits":      []string{"webservers"},
+			"inventories": []string{"5"},
+		},
+	})
+	var testsets = []struct {
+		jobTemplate string
+		request     string
+		expectErr   bool
+	}{
+		{"11111", `{"extra_vars": {"size": "large"}}`, false},
+		{"12345", `{"extra_vars": {"size": "medium"}}`, false},
+		{"12345", `{"extra_vars": {"size": "large"}}`, true},
+		{"99999", `{"extra_vars": {}}`, true},
+		{"11111", `{"extra_vars": {}, "limit": "webservers"}`, true},
+		{"23456", `{"extra_vars": {}, "limit": "webservers", "inventory": 5}`, false},
+		{"23456", `{"extra_vars": {}, "limit": ""}`, false},
+		{"23456", `{"extra_vars": {}, "limit": "all"}`, true},
+		{"23456", `{"extra_vars": {}, "inventory": 6}`, true},
+	}
+	for i, tt := range testsets {
+		err := checkPermissions(tt.jobTemplate, parseLaunchRequest(t, tt.request), "u123456")
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ERROR! testset %v: expected error: %v, got: %v", i, tt.expectErr, err)
+		}
+	}
+}
+
+func TestCheckWorkflowTemplatePermissions(t *testing.T) {
+	config.Init("bla")
+	config.Config().Set("tower.job_templates", []map[string]interface{}{{"id": "11111"}})
+	config.Config().Set("tower.workflow_job_templates", []map[string]interface{}{{"id": "22222"}})
+
+	if err := checkTemplatePermissions(workflowJobTemplateType, "22222", nil, "u123456"); err != nil {
+		t.Errorf("ERROR! expected workflow job template to be allowed, got: %v", err)
+	}
+	// The whitelists are separate
+	if err := checkTemplatePermissions(workflowJobTemplateType, "11111", nil, "u123456"); err == nil {
+		t.Errorf("ERROR! expected job template to be rejected as workflow job template")
+	}
+	if err := checkTemplatePermissions(jobTemplateType, "22222", nil, "u123456"); err == nil {
+		t.Errorf("ERROR! expected workflow job template to be rejected as job template")
+	}
+}